@@ -0,0 +1,55 @@
+// Command reencrypt-tokens mengenkripsi ulang token integrasi dengan KEK aktif
+// dari TOKEN_KEY_PROVIDER. Jalankan setelah mengganti "active" di keyfile (atau
+// TOKEN_VAULT_KEY); hapus key lama setelah job selesai.
+package main
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"ig-webhook/internal/config"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/secret"
+	"ig-webhook/internal/service"
+	"ig-webhook/internal/store"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	kp, err := secret.NewKeyProvider(cfg.TokenKeyProviderConfig())
+	if err != nil {
+		log.Fatalf("token key provider: %v", err)
+	}
+	if kp == nil {
+		log.Fatalf("TOKEN_KEY_PROVIDER is required")
+	}
+
+	ctx := context.Background()
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	pg, err := pgxpool.New(pingCtx, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("pgx connect: %v", err)
+	}
+	defer pg.Close()
+
+	kv := store.NewRedisStore(redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+	}))
+
+	svc := service.NewTokenReencryptService(repo.NewIntegrationRepo(pg, secret.NewEnvelope(kp)), kv)
+	n, err := svc.Run(ctx)
+	if err != nil {
+		log.Fatalf("reencrypt tokens (%d updated before failure): %v", n, err)
+	}
+	log.Printf("reencrypt tokens: %d updated (active key=%s)", n, kp.ActiveKeyID())
+}
@@ -6,10 +6,14 @@ import (
 	"github.com/joho/godotenv"
 	"ig-webhook/internal/config"
 	httpserver "ig-webhook/internal/http"
+	"ig-webhook/internal/ig"
 	"ig-webhook/internal/processor"
 	"ig-webhook/internal/queue"
 	"ig-webhook/internal/queue/worker"
+	"ig-webhook/internal/rate"
 	"ig-webhook/internal/repo"
+	"ig-webhook/internal/secret"
+	"ig-webhook/internal/service"
 	"ig-webhook/internal/store"
 	"log"
 	"net/http"
@@ -18,6 +22,7 @@ import (
 	"github.com/hibiken/asynq"
 	"github.com/labstack/echo/v4"
 	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
 )
 
 func mustPGPool(dsn string) *pgxpool.Pool {
@@ -54,8 +59,22 @@ func main() {
 	pg := mustPGPool(cfg.DatabaseURL)
 	defer pg.Close()
 
-	igTokenLookup := repo.NewIGTokenLookup(kv, pg)
-	httpserver.SetIGTokenLookup(igTokenLookup)
+	// Enkripsi token integrasi (at rest + cache Redis)
+	var tokenCipher *secret.Envelope
+	kp, err := secret.NewKeyProvider(cfg.TokenKeyProviderConfig())
+	if err != nil {
+		log.Fatalf("token key provider: %v", err)
+	}
+	if kp != nil {
+		tokenCipher = secret.NewEnvelope(kp)
+	} else {
+		log.Println("[WARN] TOKEN_KEY_PROVIDER not set, integration tokens stored in plaintext")
+	}
+
+	igTokenLookup := repo.NewIGTokenLookup(kv, pg, tokenCipher)
+	integrationRepo := repo.NewIntegrationRepo(pg, tokenCipher)
+	integrationStatus := repo.NewIntegrationStatusLookup(kv, pg)
+	warmup := rate.Warmup{Days: cfg.WarmupDays, StartPercent: cfg.WarmupStartPercent}
 
 	// Asynq
 	asynqDB := cfg.AsynqRedisDB
@@ -66,17 +85,57 @@ func main() {
 	}
 	asynqClient := asynq.NewClient(asynqOpt)
 	defer asynqClient.Close()
+	parker := queue.NewParker(kv, asynqClient)
+	asynqInspector := asynq.NewInspector(asynqOpt)
+	defer asynqInspector.Close()
+
+	workflowRepo := repo.NewPGWorkflowRepo(pg)
+	mediaRepo := repo.NewMediaRepo(pg)
+	deletion := &service.DataDeletionService{
+		KV:           kv,
+		Inspector:    asynqInspector,
+		Parked:       parker,
+		Integrations: integrationRepo,
+		Status:       integrationStatus,
+		Workflows:    workflowRepo,
+		Media:        mediaRepo,
+	}
+
+	// Token global hanya untuk dev; di production token selalu per integrasi
+	fallbackToken := cfg.IGPageAccessToken
+	if cfg.IsProd() && fallbackToken != "" {
+		log.Println("[WARN] IG_PAGE_ACCESS_TOKEN is ignored in production")
+		fallbackToken = ""
+	}
 
 	// Worker (consumer)
 	srv := asynq.NewServer(asynqOpt, asynq.Config{
-		Concurrency: 10,
+		Concurrency:    10,
+		RetryDelayFunc: queue.RetryDelay,
+		IsFailure:      queue.IsFailure,
 		Queues: map[string]int{
 			queue.QueueDefault:  5,
 			queue.QueuePriority: 5,
 		},
 	})
 	mux := asynq.NewServeMux()
-	worker.RegisterHandlers(mux, kv)
+	usage := rate.NewUsageTracker(kv, cfg.UsageSlowdownPercent)
+	worker.RegisterHandlers(mux, worker.Deps{
+		KV:              kv,
+		Queue:           asynqClient,
+		BrandLimits:     rate.LayerLimits{MaxHour: cfg.BrandMaxActionsPerHour, MaxDay: cfg.BrandMaxActionsPerDay},
+		AccountLimits:   rate.LayerLimits{MaxHour: cfg.AccountMaxActionsPerHour, MaxDay: cfg.AccountMaxActionsPerDay},
+		MaxStaleness:    cfg.TaskMaxStaleness,
+		DefaultTimezone: cfg.DefaultTimezone,
+		Warmup:          warmup,
+		Integrations:    integrationRepo,
+		Usage:           usage,
+		Status:          integrationStatus,
+		Tokens:          igTokenLookup,
+		FallbackToken:   fallbackToken,
+		Parked:          parker,
+		Deletion:        deletion,
+	})
 
 	// Run worker asynchronously
 	go func() {
@@ -85,16 +144,109 @@ func main() {
 		}
 	}()
 
+	mediaCatalog := service.NewMediaCatalog(mediaRepo, igTokenLookup, kv)
+
+	commentProc := processor.NewCommentProcessor(kv, asynqClient, workflowRepo)
+	commentProc.DefaultTimezone = cfg.DefaultTimezone
+	commentProc.Status = integrationStatus
+	commentProc.Media = mediaCatalog
+	commentProc.Comments = service.NewCommentLookup(igTokenLookup, kv)
+	commentStats := service.NewCommentStats(kv)
+	commentProc.Stats = commentStats
+
+	subscriptions := service.NewWebhookSubscriptionService(igTokenLookup, integrationRepo, kv)
+
+	// Job terjadwal
+	sched := cron.New(cron.WithChain(cron.Recover(cron.DefaultLogger), cron.SkipIfStillRunning(cron.DefaultLogger)))
+	if cfg.TokenRefreshCron != "" {
+		refreshJob := service.NewTokenRefreshJob(service.NewIGRefreshService(integrationRepo), kv, cfg.TokenRefreshWindow)
+		refreshJob.Status = integrationStatus
+		refreshJob.Events = asynqClient
+		if _, err := sched.AddFunc(cfg.TokenRefreshCron, func() { refreshJob.Run(context.Background()) }); err != nil {
+			log.Fatalf("cron token refresh: %v", err)
+		}
+	}
+	if cfg.SubscriptionReconcileCron != "" {
+		if _, err := sched.AddFunc(cfg.SubscriptionReconcileCron, func() { subscriptions.Reconcile(context.Background()) }); err != nil {
+			log.Fatalf("cron subscription reconcile: %v", err)
+		}
+	}
+	if cfg.CommentReconcileCron != "" {
+		reconciler := service.NewCommentReconciler(kv, igTokenLookup, integrationRepo, workflowRepo, commentProc)
+		reconciler.Usage = usage
+		reconciler.Media = mediaCatalog
+		reconciler.Lookback = cfg.CommentReconcileLookback
+		reconciler.MaxCallsPerRun = cfg.CommentReconcileMaxCalls
+		if _, err := sched.AddFunc(cfg.CommentReconcileCron, func() { reconciler.Run(context.Background()) }); err != nil {
+			log.Fatalf("cron comment reconcile: %v", err)
+		}
+	}
+	if cfg.MediaSyncCron != "" {
+		if _, err := sched.AddFunc(cfg.MediaSyncCron, func() {
+			ctx := context.Background()
+			accts, err := integrationRepo.ListActiveAccountIDs(ctx)
+			if err != nil {
+				log.Printf("[ERR] media sync: list accounts: %v", err)
+				return
+			}
+			mediaCatalog.SyncAll(ctx, accts)
+		}); err != nil {
+			log.Fatalf("cron media sync: %v", err)
+		}
+	}
+	sched.Start()
+	defer sched.Stop()
+
 	// HTTP server
 	e := echo.New()
 	e.GET("/healthz", func(c echo.Context) error { return c.String(http.StatusOK, "ok") })
 
 	// Webhook
-	workflowRepo := repo.NewPGWorkflowRepo(pg)
-	commentProc := processor.NewCommentProcessor(kv, asynqClient, workflowRepo)
 	webhook := httpserver.NewWebhookHandler(kv, asynqClient, cfg.IGAppSecret, commentProc)
 	e.POST("/webhook/instagram", webhook.HandleInstagram)
 
+	// OAuth connect Instagram
+	if cfg.OAuthEnabled() {
+		oauthCfg := ig.OAuthConfig{AppID: cfg.IGAppID, AppSecret: cfg.IGAppSecret, RedirectURI: cfg.IGOAuthRedirectURI}
+		onboarding := service.NewIGOnboardingService(oauthCfg, integrationRepo)
+		onboarding.Warmup = rate.NewLimiter(kv)
+		onboarding.OnConnected = func(ctx context.Context, accountID string, previous repo.IntegrationStatus) {
+			_ = integrationStatus.Invalidate(ctx, accountID)
+			if previous != "" && previous != repo.StatusActive {
+				queue.EmitIntegrationStatusChanged(ctx, asynqClient, queue.IntegrationStatusChangedPayload{
+					IGBusinessID: accountID,
+					Status:       string(repo.StatusActive),
+					Reason:       "reconnected (was " + string(previous) + ")",
+					At:           time.Now().UTC(),
+				})
+			}
+			if _, err := subscriptions.Ensure(ctx, accountID); err != nil {
+				log.Printf("[ERR] subscribe webhooks acct=%s: %v", accountID, err)
+			}
+			if n, err := parker.Retry(ctx, accountID); err != nil {
+				log.Printf("[ERR] retry parked tasks acct=%s: %v", accountID, err)
+			} else if n > 0 {
+				log.Printf("[OK] requeued %d parked tasks acct=%s", n, accountID)
+			}
+		}
+		oauth := httpserver.NewOAuthHandler(kv, oauthCfg, cfg.OAuthStateSecret, cfg.OAuthReturnURL, onboarding)
+		oauth.Register(e.Group("/oauth"))
+	}
+
+	// Callback Meta (deauthorize & data deletion)
+	metaCallbacks := httpserver.NewMetaCallbackHandler(cfg.IGAppSecret, cfg.PublicBaseURL, asynqClient, deletion)
+	metaCallbacks.Register(e.Group("/meta"))
+
+	// Admin (operator)
+	if cfg.AdminToken != "" {
+		admin := httpserver.NewAdminHandler(rate.NewLimiter(kv), warmup, integrationRepo, integrationStatus, parker)
+		admin.Subscriptions = subscriptions
+		admin.Media = mediaCatalog
+		admin.Stats = commentStats
+		admin.Events = asynqClient
+		admin.Register(e.Group("/admin", httpserver.AdminAuth(cfg.AdminToken)))
+	}
+
 	s := &http.Server{
 		Addr:              cfg.HTTPAddr,
 		ReadHeaderTimeout: 5 * time.Second,
@@ -2,9 +2,11 @@ package config
 
 import (
 	"fmt"
+	"ig-webhook/internal/secret"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -27,7 +29,65 @@ type Config struct {
 
 	// Instagram / Meta
 	IGAppSecret       string // untuk verifikasi X-Hub-Signature-256
-	IGPageAccessToken string // dev only (prod: ambil per-tenant dari DB/KMS)
+	IGPageAccessToken string // dev only, diabaikan di production (token per integrasi dari DB)
+
+	// OAuth connect Instagram (nonaktif kalau IG_APP_ID atau OAUTH_STATE_SECRET kosong)
+	IGAppID            string
+	IGOAuthRedirectURI string
+	OAuthStateSecret   string // HMAC state & link connect dari app utama
+	OAuthReturnURL     string // redirect setelah callback (kosong = respon JSON)
+
+	// URL publik service (status URL data deletion); kosong = dari request
+	PublicBaseURL string
+
+	// KeyProvider enkripsi token integrasi: "" (plaintext, hanya dev), "local"
+	// (keyfile, lihat secret.LoadLocalKeyFile) atau "vault" (Vault Transit).
+	// Production wajib provider berbasis KMS (vault).
+	TokenKeyProvider string
+	TokenKeyFile     string
+	VaultAddr        string
+	VaultToken       string
+	VaultTransitPath string // mount transit, default "transit"
+	TokenVaultKey    string // nama transit key
+
+	// Rate limit berlapis (di atas limit per workflow); 0 = tanpa limit
+	BrandMaxActionsPerHour   int
+	BrandMaxActionsPerDay    int
+	AccountMaxActionsPerHour int
+	AccountMaxActionsPerDay  int
+
+	// Task yang tertahan rate limit lebih lama dari ini di-drop
+	TaskMaxStaleness time.Duration
+
+	// Timezone brand default kalau workflow tidak menyetel (IANA)
+	DefaultTimezone string
+
+	// Warm-up akun IG baru: limit akun naik linear dari WarmupStartPercent
+	// ke 100% selama WarmupDays (0 = nonaktif)
+	WarmupDays         int
+	WarmupStartPercent int
+
+	// Worker melambat saat usage Meta (X-App-Usage / BUC) >= persen ini
+	UsageSlowdownPercent int
+
+	// Refresh long-lived token IG terjadwal (cron spec, kosong = nonaktif);
+	// token yang kadaluarsa dalam TokenRefreshWindow di-refresh
+	TokenRefreshCron   string
+	TokenRefreshWindow time.Duration
+
+	// Rekonsiliasi subscribed_apps (field webhook) per akun; kosong = nonaktif
+	SubscriptionReconcileCron string
+
+	// Rekonsiliasi komentar yang terlewat webhook (kosong = nonaktif)
+	CommentReconcileCron     string
+	CommentReconcileLookback time.Duration
+	CommentReconcileMaxCalls int // budget panggilan Graph API per akun per run
+
+	// Sinkron katalog media IG (kosong = nonaktif; resolve tetap sync on-demand)
+	MediaSyncCron string
+
+	// Token untuk endpoint /admin (kosong = endpoint admin nonaktif)
+	AdminToken string
 }
 
 func Load() (*Config, error) {
@@ -46,11 +106,54 @@ func Load() (*Config, error) {
 
 		IGAppSecret:       getEnv("IG_APP_SECRET", ""),
 		IGPageAccessToken: getEnv("IG_PAGE_ACCESS_TOKEN", ""),
+		TokenKeyFile:      getEnv("TOKEN_KEYFILE", ""),
+		TokenKeyProvider:  getEnv("TOKEN_KEY_PROVIDER", ""),
+		VaultAddr:         getEnv("VAULT_ADDR", ""),
+		VaultToken:        getEnv("VAULT_TOKEN", ""),
+		VaultTransitPath:  getEnv("VAULT_TRANSIT_PATH", "transit"),
+		TokenVaultKey:     getEnv("TOKEN_VAULT_KEY", ""),
+
+		IGAppID:            getEnv("IG_APP_ID", ""),
+		IGOAuthRedirectURI: getEnv("IG_OAUTH_REDIRECT_URI", ""),
+		OAuthStateSecret:   getEnv("OAUTH_STATE_SECRET", ""),
+		OAuthReturnURL:     getEnv("OAUTH_RETURN_URL", ""),
+		PublicBaseURL:      getEnv("PUBLIC_BASE_URL", ""),
+
+		BrandMaxActionsPerHour:   getEnvInt("RL_BRAND_MAX_PER_HOUR", 25),
+		BrandMaxActionsPerDay:    getEnvInt("RL_BRAND_MAX_PER_DAY", 200),
+		AccountMaxActionsPerHour: getEnvInt("RL_ACCOUNT_MAX_PER_HOUR", 50),
+		AccountMaxActionsPerDay:  getEnvInt("RL_ACCOUNT_MAX_PER_DAY", 400),
+
+		TaskMaxStaleness: getEnvDuration("TASK_MAX_STALENESS", 12*time.Hour),
+		DefaultTimezone:  getEnv("DEFAULT_TIMEZONE", "UTC"),
+
+		WarmupDays:         getEnvInt("WARMUP_DAYS", 14),
+		WarmupStartPercent: getEnvInt("WARMUP_START_PERCENT", 10),
+
+		UsageSlowdownPercent: getEnvInt("USAGE_SLOWDOWN_PERCENT", 80),
+
+		TokenRefreshCron:   getEnv("TOKEN_REFRESH_CRON", "0 * * * *"),
+		TokenRefreshWindow: getEnvDuration("TOKEN_REFRESH_WINDOW", 7*24*time.Hour),
+
+		SubscriptionReconcileCron: getEnv("SUBSCRIPTION_RECONCILE_CRON", "30 */6 * * *"),
+
+		CommentReconcileCron:     getEnv("COMMENT_RECONCILE_CRON", "*/15 * * * *"),
+		CommentReconcileLookback: getEnvDuration("COMMENT_RECONCILE_LOOKBACK", 2*time.Hour),
+		CommentReconcileMaxCalls: getEnvInt("COMMENT_RECONCILE_MAX_CALLS", 20),
+
+		MediaSyncCron: getEnv("MEDIA_SYNC_CRON", "15 * * * *"),
+
+		AdminToken: getEnv("ADMIN_TOKEN", ""),
 	}
 
 	// Normalisasi
 	cfg.AppEnv = strings.ToLower(strings.TrimSpace(cfg.AppEnv))
 	cfg.LogLevel = strings.ToLower(strings.TrimSpace(cfg.LogLevel))
+	cfg.TokenKeyProvider = strings.ToLower(strings.TrimSpace(cfg.TokenKeyProvider))
+	if cfg.TokenKeyProvider == "" && cfg.TokenKeyFile != "" {
+		// deployment lama: TOKEN_KEYFILE tanpa TOKEN_KEY_PROVIDER
+		cfg.TokenKeyProvider = secret.ProviderLocal
+	}
 
 	// Validasi
 	if err := cfg.Validate(); err != nil {
@@ -81,6 +184,38 @@ func (c *Config) Validate() error {
 			missing = append(missing, "IG_APP_SECRET")
 		}
 		// IG_PAGE_ACCESS_TOKEN boleh kosong di prod (ambil per-tenant dari DB/KMS)
+		// KEK di file lokal tidak cukup untuk prod: wajib KMS
+		if c.TokenKeyProvider != secret.ProviderVault {
+			return fmt.Errorf("TOKEN_KEY_PROVIDER must be %q in production, got %q", secret.ProviderVault, c.TokenKeyProvider)
+		}
+	}
+
+	switch c.TokenKeyProvider {
+	case secret.ProviderNone:
+	case secret.ProviderLocal:
+		if c.TokenKeyFile == "" {
+			missing = append(missing, "TOKEN_KEYFILE")
+		}
+	case secret.ProviderVault:
+		if c.VaultAddr == "" {
+			missing = append(missing, "VAULT_ADDR")
+		}
+		if c.VaultToken == "" {
+			missing = append(missing, "VAULT_TOKEN")
+		}
+		if c.TokenVaultKey == "" {
+			missing = append(missing, "TOKEN_VAULT_KEY")
+		}
+	default:
+		return fmt.Errorf("invalid TOKEN_KEY_PROVIDER %q (want local or vault)", c.TokenKeyProvider)
+	}
+
+	if c.OAuthEnabled() && c.IGOAuthRedirectURI == "" {
+		missing = append(missing, "IG_OAUTH_REDIRECT_URI")
+	}
+
+	if _, err := time.LoadLocation(c.DefaultTimezone); err != nil {
+		return fmt.Errorf("invalid DEFAULT_TIMEZONE %q: %w", c.DefaultTimezone, err)
 	}
 
 	if len(missing) > 0 {
@@ -89,10 +224,26 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// TokenKeyProviderConfig: pilihan KeyProvider untuk secret.NewKeyProvider.
+func (c *Config) TokenKeyProviderConfig() secret.ProviderConfig {
+	return secret.ProviderConfig{
+		Kind:       c.TokenKeyProvider,
+		KeyFile:    c.TokenKeyFile,
+		VaultAddr:  c.VaultAddr,
+		VaultToken: c.VaultToken,
+		VaultMount: c.VaultTransitPath,
+		VaultKey:   c.TokenVaultKey,
+	}
+}
+
 func (c *Config) IsProd() bool {
 	return c.AppEnv == "production"
 }
 
+func (c *Config) OAuthEnabled() bool {
+	return c.IGAppID != "" && c.OAuthStateSecret != ""
+}
+
 // --- helpers ---
 
 func getEnv(key, def string) string {
@@ -120,3 +271,12 @@ func getEnvInt(key string, def int) int {
 	}
 	return def
 }
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
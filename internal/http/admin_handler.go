@@ -0,0 +1,276 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/rate"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+)
+
+// AdminHandler: endpoint operator (warm-up, dsb). Dilindungi AdminAuth.
+type AdminHandler struct {
+	lim          *rate.Limiter
+	warmup       rate.Warmup
+	integrations *repo.IntegrationRepo
+	status       *repo.IntegrationStatusLookup
+	parked       *queue.Parker
+
+	// Subscriptions opsional: route subscribed_apps hanya dipasang kalau diset
+	Subscriptions *service.WebhookSubscriptionService
+	// Media opsional: katalog media (sync & resolve permalink/shortcode)
+	Media *service.MediaCatalog
+	// Stats opsional: analytics komentar organik vs iklan
+	Stats *service.CommentStats
+	// Events opsional: enqueue integration:status_changed saat status diubah operator
+	Events *asynq.Client
+}
+
+func NewAdminHandler(lim *rate.Limiter, warmup rate.Warmup, integrations *repo.IntegrationRepo, status *repo.IntegrationStatusLookup, parked *queue.Parker) *AdminHandler {
+	return &AdminHandler{lim: lim, warmup: warmup, integrations: integrations, status: status, parked: parked}
+}
+
+// AdminAuth memeriksa header "Authorization: Bearer <ADMIN_TOKEN>".
+func AdminAuth(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			got := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+			if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				return c.NoContent(http.StatusUnauthorized)
+			}
+			return next(c)
+		}
+	}
+}
+
+// Register memasang route admin pada group (mis. /admin).
+func (h *AdminHandler) Register(g *echo.Group) {
+	g.GET("/integrations/:accountId/warmup", h.GetWarmup)
+	g.PUT("/integrations/:accountId/warmup", h.PutWarmup)
+	g.POST("/integrations/:accountId/warmup/restart", h.RestartWarmup)
+	g.GET("/integrations/:accountId/status", h.GetStatus)
+	g.PUT("/integrations/:accountId/status", h.PutStatus)
+	g.GET("/integrations/:accountId/parked", h.ListParked)
+	g.POST("/integrations/:accountId/parked/retry", h.RetryParked)
+	if h.Subscriptions != nil {
+		g.GET("/integrations/:accountId/subscriptions", h.ListSubscriptions)
+		g.PUT("/integrations/:accountId/subscriptions", h.PutSubscriptions)
+		g.DELETE("/integrations/:accountId/subscriptions", h.DeleteSubscriptions)
+	}
+	if h.Media != nil {
+		g.POST("/integrations/:accountId/media/sync", h.SyncMedia)
+		g.POST("/integrations/:accountId/media/resolve", h.ResolveMedia)
+	}
+	if h.Stats != nil {
+		g.GET("/integrations/:accountId/comment-stats", h.GetCommentStats)
+	}
+}
+
+type warmupStatus struct {
+	AccountID   string               `json:"accountId"`
+	Override    *rate.WarmupOverride `json:"override,omitempty"`
+	ConnectedAt *time.Time           `json:"connectedAt,omitempty"`
+	StartAt     *time.Time           `json:"startAt,omitempty"`
+	Factor      float64              `json:"factor"`
+}
+
+func (h *AdminHandler) GetWarmup(c echo.Context) error {
+	ctx := c.Request().Context()
+	acct := c.Param("accountId")
+
+	st := warmupStatus{AccountID: acct, Factor: 1}
+	o, err := h.lim.WarmupOverride(ctx, acct)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	st.Override = o
+
+	var connected time.Time
+	if h.integrations != nil {
+		if t, err := h.integrations.ConnectedAt(ctx, acct); err == nil {
+			connected = t
+			st.ConnectedAt = &t
+		}
+	}
+	start, ok, err := h.lim.WarmupStart(ctx, acct, connected)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if ok {
+		st.StartAt = &start
+		st.Factor = h.warmup.Factor(start, time.Now())
+	}
+	return c.JSON(http.StatusOK, st)
+}
+
+func (h *AdminHandler) PutWarmup(c echo.Context) error {
+	var o rate.WarmupOverride
+	if err := c.Bind(&o); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := h.lim.SetWarmupOverride(c.Request().Context(), c.Param("accountId"), o); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return h.GetWarmup(c)
+}
+
+func (h *AdminHandler) RestartWarmup(c echo.Context) error {
+	if err := h.lim.RestartWarmup(c.Request().Context(), c.Param("accountId")); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return h.GetWarmup(c)
+}
+
+type statusBody struct {
+	Status repo.IntegrationStatus `json:"status"`
+	Reason string                 `json:"reason,omitempty"`
+}
+
+func (h *AdminHandler) GetStatus(c echo.Context) error {
+	s, err := h.status.Status(c.Request().Context(), c.Param("accountId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, statusBody{Status: s})
+}
+
+// PutStatus: operator mengaktifkan ulang / men-suspend integrasi. Saat
+// diaktifkan ulang, task yang di-park langsung di-enqueue ulang.
+func (h *AdminHandler) PutStatus(c echo.Context) error {
+	ctx := c.Request().Context()
+	acct := c.Param("accountId")
+	var b statusBody
+	if err := c.Bind(&b); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if !b.Status.Valid() {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid status")
+	}
+	changed, err := h.status.SetStatus(ctx, acct, b.Status, b.Reason)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if changed {
+		queue.EmitIntegrationStatusChanged(ctx, h.Events, queue.IntegrationStatusChangedPayload{
+			IGBusinessID: acct,
+			Status:       string(b.Status),
+			Reason:       b.Reason,
+			At:           time.Now().UTC(),
+		})
+	}
+	if b.Status.Usable() && h.parked != nil {
+		if _, err := h.parked.Retry(ctx, acct); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+	return h.GetStatus(c)
+}
+
+func (h *AdminHandler) ListParked(c echo.Context) error {
+	tasks, err := h.parked.List(c.Request().Context(), c.Param("accountId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, tasks)
+}
+
+func (h *AdminHandler) RetryParked(c echo.Context) error {
+	n, err := h.parked.Retry(c.Request().Context(), c.Param("accountId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]int{"requeued": n})
+}
+
+type subscriptionsBody struct {
+	Fields []string `json:"fields"`
+}
+
+func (h *AdminHandler) ListSubscriptions(c echo.Context) error {
+	fields, err := h.Subscriptions.List(c.Request().Context(), c.Param("accountId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	return c.JSON(http.StatusOK, subscriptionsBody{Fields: fields})
+}
+
+// PutSubscriptions: body {"fields": [...]}; kosong = field default.
+func (h *AdminHandler) PutSubscriptions(c echo.Context) error {
+	var b subscriptionsBody
+	if err := c.Bind(&b); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := h.Subscriptions.Subscribe(c.Request().Context(), c.Param("accountId"), b.Fields); err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	return h.ListSubscriptions(c)
+}
+
+func (h *AdminHandler) DeleteSubscriptions(c echo.Context) error {
+	if err := h.Subscriptions.Unsubscribe(c.Request().Context(), c.Param("accountId")); err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AdminHandler) SyncMedia(c echo.Context) error {
+	n, err := h.Media.Sync(c.Request().Context(), c.Param("accountId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]int{"added": n})
+}
+
+type resolveMediaBody struct {
+	Refs []string `json:"refs"`
+}
+
+type resolveMediaResult struct {
+	IDs    map[string]string `json:"ids"`              // ref → media id
+	Errors map[string]string `json:"errors,omitempty"` // ref → alasan
+}
+
+// ResolveMedia: dipanggil editor workflow saat menyimpan SelectedPostID,
+// supaya permalink/shortcode tersimpan sebagai media id.
+func (h *AdminHandler) ResolveMedia(c echo.Context) error {
+	ctx := c.Request().Context()
+	acct := c.Param("accountId")
+	var b resolveMediaBody
+	if err := c.Bind(&b); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	res := resolveMediaResult{IDs: map[string]string{}, Errors: map[string]string{}}
+	for _, ref := range b.Refs {
+		id, err := h.Media.Resolve(ctx, acct, ref)
+		if err != nil {
+			res.Errors[ref] = err.Error()
+			continue
+		}
+		res.IDs[ref] = id
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetCommentStats: ?days=N (default 7, maks 90), terbaru dulu.
+func (h *AdminHandler) GetCommentStats(c echo.Context) error {
+	days := 7
+	if v := c.QueryParam("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 90 {
+			return echo.NewHTTPError(http.StatusBadRequest, "days must be 1..90")
+		}
+		days = n
+	}
+	st, err := h.Stats.Daily(c.Request().Context(), c.Param("accountId"), days)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, st)
+}
@@ -0,0 +1,137 @@
+package httpserver
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/service"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+// MetaCallbackHandler: callback wajib Meta App (deauthorize & data deletion).
+//
+//	POST /meta/deauthorize            signed_request → integrasi revoked, task antre dibatalkan
+//	POST /meta/data-deletion          signed_request → purge async, balas {url, confirmation_code}
+//	GET  /meta/data-deletion/:code    status penghapusan
+type MetaCallbackHandler struct {
+	appSecret string
+	baseURL   string // URL publik service (kosong = dari request)
+	q         *asynq.Client
+	deletion  *service.DataDeletionService
+}
+
+func NewMetaCallbackHandler(appSecret, baseURL string, q *asynq.Client, deletion *service.DataDeletionService) *MetaCallbackHandler {
+	return &MetaCallbackHandler{appSecret: appSecret, baseURL: strings.TrimRight(baseURL, "/"), q: q, deletion: deletion}
+}
+
+func (h *MetaCallbackHandler) Register(g *echo.Group) {
+	g.POST("/deauthorize", h.Deauthorize)
+	g.POST("/data-deletion", h.DataDeletion)
+	g.GET("/data-deletion/:code", h.DeletionStatus)
+}
+
+func (h *MetaCallbackHandler) Deauthorize(c echo.Context) error {
+	sr, err := parseSignedRequest(h.appSecret, c.FormValue("signed_request"))
+	if err != nil {
+		return c.NoContent(http.StatusForbidden)
+	}
+	if err := h.deletion.Revoke(c.Request().Context(), sr.UserID); err != nil {
+		log.Printf("[ERR] deauthorize acct=%s: %v", sr.UserID, err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *MetaCallbackHandler) DataDeletion(c echo.Context) error {
+	ctx := c.Request().Context()
+	sr, err := parseSignedRequest(h.appSecret, c.FormValue("signed_request"))
+	if err != nil {
+		return c.NoContent(http.StatusForbidden)
+	}
+
+	p := queue.PurgeAccountDataPayload{
+		IGBusinessID:     sr.UserID,
+		ConfirmationCode: randomHex(10),
+		RequestedAt:      time.Now().UTC(),
+	}
+	if err := h.deletion.SaveStatus(ctx, service.DeletionStatus{
+		ConfirmationCode: p.ConfirmationCode,
+		Status:           "pending",
+		RequestedAt:      p.RequestedAt,
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	t, opts := queue.NewPurgeAccountDataTask(p)
+	if _, err := h.q.EnqueueContext(ctx, t, opts...); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	base := h.baseURL
+	if base == "" {
+		base = c.Scheme() + "://" + c.Request().Host
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"url":               base + "/meta/data-deletion/" + p.ConfirmationCode,
+		"confirmation_code": p.ConfirmationCode,
+	})
+}
+
+func (h *MetaCallbackHandler) DeletionStatus(c echo.Context) error {
+	st, err := h.deletion.GetStatus(c.Request().Context(), c.Param("code"))
+	if errors.Is(err, redis.Nil) {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, st)
+}
+
+type signedRequest struct {
+	Algorithm string `json:"algorithm"`
+	IssuedAt  int64  `json:"issued_at"`
+	UserID    string `json:"user_id"`
+}
+
+var errInvalidSignedRequest = errors.New("invalid signed_request")
+
+// parseSignedRequest memverifikasi "<sig>.<payload>" (base64url) dengan
+// HMAC-SHA256(app secret, payload).
+func parseSignedRequest(appSecret, s string) (signedRequest, error) {
+	var sr signedRequest
+	encSig, payload, ok := strings.Cut(s, ".")
+	if !ok || appSecret == "" {
+		return sr, errInvalidSignedRequest
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encSig, "="))
+	if err != nil || !hmac.Equal(sig, hmacSHA256([]byte(appSecret), payload)) {
+		return sr, errInvalidSignedRequest
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload, "="))
+	if err != nil {
+		return sr, errInvalidSignedRequest
+	}
+	// user_id bisa string atau number
+	var raw struct {
+		signedRequest
+		UserID json.Number `json:"user_id"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return sr, errInvalidSignedRequest
+	}
+	sr = raw.signedRequest
+	sr.UserID = raw.UserID.String()
+	if !strings.EqualFold(sr.Algorithm, "HMAC-SHA256") || sr.UserID == "" {
+		return sr, errInvalidSignedRequest
+	}
+	return sr, nil
+}
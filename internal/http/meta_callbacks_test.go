@@ -0,0 +1,36 @@
+package httpserver
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func makeSignedRequest(secret, payload string) string {
+	p := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(hmacSHA256([]byte(secret), p)) + "." + p
+}
+
+func TestParseSignedRequest(t *testing.T) {
+	sr := makeSignedRequest("app-secret", `{"algorithm":"HMAC-SHA256","issued_at":1700000000,"user_id":"17841400000000001"}`)
+	got, err := parseSignedRequest("app-secret", sr)
+	if err != nil || got.UserID != "17841400000000001" {
+		t.Fatalf("parse: %+v, %v", got, err)
+	}
+
+	// user_id numerik
+	sr = makeSignedRequest("app-secret", `{"algorithm":"HMAC-SHA256","user_id":1789}`)
+	if got, err := parseSignedRequest("app-secret", sr); err != nil || got.UserID != "1789" {
+		t.Fatalf("numeric user_id: %+v, %v", got, err)
+	}
+
+	if _, err := parseSignedRequest("other-secret", sr); err == nil {
+		t.Fatalf("expected failure with wrong secret")
+	}
+	if _, err := parseSignedRequest("app-secret", "garbage"); err == nil {
+		t.Fatalf("expected failure for malformed input")
+	}
+	bad := makeSignedRequest("app-secret", `{"algorithm":"plain","user_id":"1"}`)
+	if _, err := parseSignedRequest("app-secret", bad); err == nil {
+		t.Fatalf("expected failure for unsupported algorithm")
+	}
+}
@@ -0,0 +1,214 @@
+package httpserver
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/service"
+	"ig-webhook/internal/store"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const oauthStateTTL = 15 * time.Minute
+
+// Cookie nonce mengikat state ke browser yang memulai alur (anti OAuth CSRF).
+const oauthNonceCookie = "ig_oauth_nonce"
+
+// OAuthHandler: alur connect Instagram.
+//
+//	GET /oauth/instagram/authorize?user_id=..&exp=..&sig=..
+//	    Link connect dibuat app utama: sig = hex(HMAC-SHA256(OAUTH_STATE_SECRET, user_id + "." + exp)).
+//	    Redirect ke consent Instagram dengan state bertanda tangan; nonce state
+//	    juga disimpan di cookie HttpOnly.
+//	GET /oauth/instagram/callback?code=..&state=..
+//	    Nonce state harus sama dengan cookie. Tukar code → long-lived token, simpan integrasi, redirect ke OAUTH_RETURN_URL.
+type OAuthHandler struct {
+	kv        *store.RedisStore
+	oauth     ig.OAuthConfig
+	secret    []byte
+	returnURL string
+	svc       *service.IGOnboardingService
+}
+
+func NewOAuthHandler(kv *store.RedisStore, o ig.OAuthConfig, stateSecret, returnURL string, svc *service.IGOnboardingService) *OAuthHandler {
+	return &OAuthHandler{kv: kv, oauth: o, secret: []byte(stateSecret), returnURL: returnURL, svc: svc}
+}
+
+func (h *OAuthHandler) Register(g *echo.Group) {
+	g.GET("/instagram/authorize", h.Authorize)
+	g.GET("/instagram/callback", h.Callback)
+}
+
+func (h *OAuthHandler) Authorize(c echo.Context) error {
+	userID := c.QueryParam("user_id")
+	exp, _ := strconv.ParseInt(c.QueryParam("exp"), 10, 64)
+	if userID == "" || exp == 0 || !verifyConnectLink(h.secret, userID, exp, c.QueryParam("sig"), time.Now()) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	nonce := randomHex(16)
+	state, err := signState(h.secret, oauthState{
+		UserID:  userID,
+		Nonce:   nonce,
+		Expires: time.Now().Add(oauthStateTTL).Unix(),
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	c.SetCookie(h.nonceCookie(c, nonce, int(oauthStateTTL.Seconds())))
+	return c.Redirect(http.StatusFound, h.oauth.AuthorizeURL(state))
+}
+
+func (h *OAuthHandler) Callback(c echo.Context) error {
+	ctx := c.Request().Context()
+	if e := c.QueryParam("error"); e != "" {
+		// user menolak consent
+		return h.finish(c, "denied", url.Values{"reason": {c.QueryParam("error_reason")}})
+	}
+	st, err := verifyState(h.secret, c.QueryParam("state"), time.Now())
+	if err != nil {
+		return c.NoContent(http.StatusForbidden)
+	}
+	// state harus berasal dari browser ini (cookie dari Authorize)
+	ck, err := c.Cookie(oauthNonceCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(ck.Value), []byte(st.Nonce)) != 1 {
+		return c.NoContent(http.StatusForbidden)
+	}
+	c.SetCookie(h.nonceCookie(c, "", -1))
+	// state hanya boleh dipakai sekali
+	ok, err := h.kv.AcquireOnce(ctx, "oauth:state:"+st.Nonce, oauthStateTTL)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if !ok {
+		return c.NoContent(http.StatusForbidden)
+	}
+	code := c.QueryParam("code")
+	if code == "" {
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	res, err := h.svc.Connect(ctx, st.UserID, code)
+	if errors.Is(err, repo.ErrAccountOwned) {
+		log.Printf("[WARN] instagram connect user=%s: %v", st.UserID, err)
+		return h.finish(c, "account_in_use", nil)
+	}
+	if err != nil {
+		log.Printf("[ERR] instagram connect user=%s: %v", st.UserID, err)
+		return h.finish(c, "error", nil)
+	}
+	return h.finish(c, "connected", url.Values{"account_id": {res.AccountID}, "username": {res.Username}})
+}
+
+func (h *OAuthHandler) nonceCookie(c echo.Context, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oauthNonceCookie,
+		Value:    value,
+		Path:     "/oauth/instagram",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		// Lax: cookie tetap terkirim pada redirect top-level dari Instagram
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// finish redirect ke app utama kalau OAUTH_RETURN_URL diset; kalau tidak, JSON.
+func (h *OAuthHandler) finish(c echo.Context, status string, extra url.Values) error {
+	if h.returnURL == "" {
+		body := map[string]string{"status": status}
+		for k := range extra {
+			body[k] = extra.Get(k)
+		}
+		code := http.StatusOK
+		if status != "connected" {
+			code = http.StatusBadRequest
+		}
+		return c.JSON(code, body)
+	}
+	u, err := url.Parse(h.returnURL)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	q := u.Query()
+	q.Set("status", status)
+	for k := range extra {
+		q.Set(k, extra.Get(k))
+	}
+	u.RawQuery = q.Encode()
+	return c.Redirect(http.StatusFound, u.String())
+}
+
+type oauthState struct {
+	UserID  string `json:"u"`
+	Nonce   string `json:"n"`
+	Expires int64  `json:"e"`
+}
+
+var errInvalidState = errors.New("invalid oauth state")
+
+// signState: base64url(json) + "." + base64url(HMAC-SHA256)
+func signState(secret []byte, st oauthState) (string, error) {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(hmacSHA256(secret, payload)), nil
+}
+
+func verifyState(secret []byte, s string, now time.Time) (oauthState, error) {
+	var st oauthState
+	payload, sig, ok := strings.Cut(s, ".")
+	if !ok {
+		return st, errInvalidState
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, hmacSHA256(secret, payload)) {
+		return st, errInvalidState
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(b, &st) != nil {
+		return st, errInvalidState
+	}
+	if st.UserID == "" || st.Nonce == "" || now.Unix() > st.Expires {
+		return st, errInvalidState
+	}
+	return st, nil
+}
+
+func verifyConnectLink(secret []byte, userID string, exp int64, sig string, now time.Time) bool {
+	if now.Unix() > exp {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(got, hmacSHA256(secret, userID+"."+strconv.FormatInt(exp, 10)))
+}
+
+func hmacSHA256(secret []byte, msg string) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(msg))
+	return m.Sum(nil)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
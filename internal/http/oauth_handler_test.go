@@ -0,0 +1,99 @@
+package httpserver
+
+import (
+	"encoding/hex"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestOAuthStateRoundTrip(t *testing.T) {
+	secret := []byte("state-secret")
+	now := time.Now()
+	s, err := signState(secret, oauthState{UserID: "user-1", Nonce: "n1", Expires: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	st, err := verifyState(secret, s, now)
+	if err != nil || st.UserID != "user-1" {
+		t.Fatalf("verify: %+v, %v", st, err)
+	}
+
+	if _, err := verifyState([]byte("other"), s, now); err == nil {
+		t.Fatalf("expected failure with wrong secret")
+	}
+	payload, sig, _ := strings.Cut(s, ".")
+	if _, err := verifyState(secret, payload+"x."+sig, now); err == nil {
+		t.Fatalf("expected failure for tampered payload")
+	}
+	if _, err := verifyState(secret, s, now.Add(2*time.Minute)); err == nil {
+		t.Fatalf("expected failure for expired state")
+	}
+}
+
+func TestOAuthCallbackRequiresNonceCookie(t *testing.T) {
+	mr := miniredis.RunT(t)
+	kv := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	secret := "s3cret"
+	h := NewOAuthHandler(kv, ig.OAuthConfig{AppID: "app", RedirectURI: "https://x/cb"}, secret, "", nil)
+	e := echo.New()
+
+	// Authorize: set cookie nonce yang sama dengan state
+	exp := time.Now().Add(time.Hour).Unix()
+	sig := hex.EncodeToString(hmacSHA256([]byte(secret), "u1."+strconv.FormatInt(exp, 10)))
+	req := httptest.NewRequest(http.MethodGet, "/oauth/instagram/authorize?user_id=u1&exp="+strconv.FormatInt(exp, 10)+"&sig="+sig, nil)
+	rec := httptest.NewRecorder()
+	if err := h.Authorize(e.NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusFound {
+		t.Fatalf("authorize status %d", rec.Code)
+	}
+	var cookie *http.Cookie
+	for _, ck := range rec.Result().Cookies() {
+		if ck.Name == oauthNonceCookie {
+			cookie = ck
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly {
+		t.Fatalf("nonce cookie missing or not HttpOnly: %+v", cookie)
+	}
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	state := loc.Query().Get("state")
+	st, err := verifyState([]byte(secret), state, time.Now())
+	if err != nil || st.Nonce != cookie.Value {
+		t.Fatalf("state nonce %q does not match cookie %q (%v)", st.Nonce, cookie.Value, err)
+	}
+
+	callback := func(ck *http.Cookie) int {
+		// tanpa code: lolos cek cookie → 400, ditolak → 403
+		req := httptest.NewRequest(http.MethodGet, "/oauth/instagram/callback?state="+url.QueryEscape(state), nil)
+		if ck != nil {
+			req.AddCookie(ck)
+		}
+		rec := httptest.NewRecorder()
+		if err := h.Callback(e.NewContext(req, rec)); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code
+	}
+	if code := callback(nil); code != http.StatusForbidden {
+		t.Fatalf("callback without cookie: %d", code)
+	}
+	if code := callback(&http.Cookie{Name: oauthNonceCookie, Value: "other"}); code != http.StatusForbidden {
+		t.Fatalf("callback with foreign cookie: %d", code)
+	}
+	if code := callback(&http.Cookie{Name: oauthNonceCookie, Value: cookie.Value}); code != http.StatusBadRequest {
+		t.Fatalf("callback with matching cookie: %d", code)
+	}
+}
@@ -7,13 +7,11 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"ig-webhook/internal/processor"
-	"ig-webhook/internal/repo"
 	"ig-webhook/internal/store"
 	"ig-webhook/internal/types"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/hibiken/asynq"
@@ -73,22 +71,31 @@ func (h *WebhookHandler) process(body []byte) {
 	}
 
 	for _, entry := range bodyRq.Entry {
-		brandID := mapPageToBrand(entry.ID)
+		brandID := processor.BrandIDForAccount(entry.ID)
+		commentedAt := time.Now().UTC()
+		if entry.Time > 0 {
+			commentedAt = time.Unix(entry.Time, 0).UTC()
+		}
 		for _, ch := range entry.Changes {
 			if ch.Field != "comments" && ch.Field != "ig_comments" {
 				continue
 			}
 
+			postID := commentPostID(ch.Value.PostID, ch.Value.Media.OriginalMediaID, ch.Value.Media.ID)
+
 			ev := processor.CommentEvent{
-				EventID:       ch.Value.CommentID, // boleh gabung timestamp kalau perlu
-				BrandID:       brandID,
-				IGBusinessID:  entry.ID,
-				CommentID:     ch.Value.CommentID,
-				PostID:        ch.Value.PostID,
-				Text:          ch.Value.Text,
-				FromIGUserID:  ch.Value.From.ID,
-				FromUsername:  ch.Value.From.Username,
-				IGAccessToken: lookupIGToken(brandID),
+				EventID:         ch.Value.CommentID, // boleh gabung timestamp kalau perlu
+				BrandID:         brandID,
+				IGBusinessID:    entry.ID,
+				CommentID:       ch.Value.CommentID,
+				PostID:          postID,
+				ParentCommentID: ch.Value.ParentID,
+				AdID:            ch.Value.Media.AdID,
+				AdTitle:         ch.Value.Media.AdTitle,
+				Text:            ch.Value.Text,
+				FromIGUserID:    ch.Value.From.ID,
+				FromUsername:    ch.Value.From.Username,
+				CommentedAt:     commentedAt,
 			}
 
 			if h.commentProc == nil {
@@ -117,30 +124,16 @@ func verifySignature(appSecret string, body []byte, sigHeader string) bool {
 	return hmac.Equal([]byte(sigProvided), []byte(expected))
 }
 
-func mapPageToBrand(pageID string) string {
-	// TODO: lookup DB mapping pageID -> brand/tenant
-	return "brand-" + pageID
-}
-
-var igTokenLkp *repo.IGTokenLookup
-
-func SetIGTokenLookup(l *repo.IGTokenLookup) {
-	igTokenLkp = l
-}
-
-func lookupIGToken(brandID string) string {
-	// fallback env jika belum di-inject / error
-	fallback := os.Getenv("IG_PAGE_ACCESS_TOKEN")
-	if igTokenLkp == nil || brandID == "" {
-		return fallback
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-
-	token, err := igTokenLkp.Lookup(ctx, brandID)
-	if err != nil || token == "" {
-		return fallback
+// commentPostID: komentar iklan membawa original_media_id (post organik di
+// balik iklan), dipakai supaya targeting per post tetap cocok. Tanpa itu
+// post_id, lalu media.id (media iklan).
+func commentPostID(postID, originalMediaID, mediaID string) string {
+	switch {
+	case originalMediaID != "":
+		return originalMediaID
+	case postID != "":
+		return postID
+	default:
+		return mediaID
 	}
-	return token
 }
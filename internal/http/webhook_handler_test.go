@@ -0,0 +1,19 @@
+package httpserver
+
+import "testing"
+
+func TestCommentPostID(t *testing.T) {
+	cases := []struct {
+		postID, original, media, want string
+	}{
+		{"p1", "", "m1", "p1"},
+		{"", "", "m1", "m1"},
+		{"ad-post", "orig1", "ad-media", "orig1"},
+		{"", "orig1", "ad-media", "orig1"},
+	}
+	for _, c := range cases {
+		if got := commentPostID(c.postID, c.original, c.media); got != c.want {
+			t.Errorf("commentPostID(%q, %q, %q) = %q, want %q", c.postID, c.original, c.media, got, c.want)
+		}
+	}
+}
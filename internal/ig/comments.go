@@ -0,0 +1,132 @@
+package ig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Format timestamp Graph API, mis. "2024-05-01T10:00:00+0000".
+const graphTimeLayout = "2006-01-02T15:04:05-0700"
+
+// GraphTime: timestamp Graph API.
+type GraphTime struct{ time.Time }
+
+func (t *GraphTime) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+	v, err := time.Parse(graphTimeLayout, s)
+	if err != nil {
+		if v, err = time.Parse(time.RFC3339, s); err != nil {
+			return err
+		}
+	}
+	t.Time = v
+	return nil
+}
+
+type CommentAuthor struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type Comment struct {
+	ID        string        `json:"id"`
+	Text      string        `json:"text"`
+	Timestamp GraphTime     `json:"timestamp"`
+	Username  string        `json:"username"`
+	From      CommentAuthor `json:"from"`
+	ParentID  string        `json:"parent_id,omitempty"`
+}
+
+type CommentsPage struct {
+	Comments []Comment
+	After    string // cursor halaman berikutnya; kosong = habis
+}
+
+// ListComments: GET {BaseURL}/{ver}/{media-id}/comments (urutan terbaru dulu).
+// Balasan (edge replies, halaman pertama) ikut di Comments setelah komentar
+// induknya, dengan ParentID terisi.
+func (c *Client) ListComments(ctx context.Context, mediaID, after string, limit int) (*CommentsPage, error) {
+	u, _ := url.Parse(fmt.Sprintf("%s/%s/%s/comments", c.BaseURL, c.APIVersion, mediaID))
+	q := u.Query()
+	q.Set("fields", "id,text,timestamp,username,from{id,username},parent_id,"+
+		"replies{id,text,timestamp,username,from{id,username},parent_id}")
+	q.Set("limit", fmt.Sprint(limit))
+	q.Set("access_token", c.APIToken)
+	if after != "" {
+		q.Set("after", after)
+	}
+	u.RawQuery = q.Encode()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list comments: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, parseGraphError("ListComments", resp)
+	}
+	var out struct {
+		Data []struct {
+			Comment
+			Replies struct {
+				Data []Comment `json:"data"`
+			} `json:"replies"`
+		} `json:"data"`
+		Paging struct {
+			Cursors struct {
+				After string `json:"after"`
+			} `json:"cursors"`
+			Next string `json:"next"`
+		} `json:"paging"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	page := &CommentsPage{}
+	for _, top := range out.Data {
+		page.Comments = append(page.Comments, top.Comment)
+		for _, r := range top.Replies.Data {
+			if r.ParentID == "" {
+				r.ParentID = top.ID
+			}
+			page.Comments = append(page.Comments, r)
+		}
+	}
+	if out.Paging.Next != "" {
+		page.After = out.Paging.Cursors.After
+	}
+	return page, nil
+}
+
+// GetComment: GET {BaseURL}/{ver}/{comment-id}
+func (c *Client) GetComment(ctx context.Context, commentID string) (*Comment, error) {
+	u, _ := url.Parse(fmt.Sprintf("%s/%s/%s", c.BaseURL, c.APIVersion, commentID))
+	q := u.Query()
+	q.Set("fields", "id,text,timestamp,username,from{id,username},parent_id")
+	q.Set("access_token", c.APIToken)
+	u.RawQuery = q.Encode()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get comment: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, parseGraphError("GetComment", resp)
+	}
+	var cm Comment
+	if err := json.NewDecoder(resp.Body).Decode(&cm); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return &cm, nil
+}
@@ -0,0 +1,121 @@
+package ig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrorClass menentukan cara worker menangani error Graph API.
+type ErrorClass string
+
+const (
+	ClassRetryable        ErrorClass = "retryable"         // retry dengan backoff
+	ClassRateLimited      ErrorClass = "rate_limited"      // tunda sampai kuota pulih
+	ClassTokenInvalid     ErrorClass = "token_invalid"     // token dicabut/kadaluarsa
+	ClassPermissionDenied ErrorClass = "permission_denied" // izin app dicabut
+	ClassPermanent        ErrorClass = "permanent"         // request tidak akan berhasil
+)
+
+// GraphError: body error Graph API ({"error": {...}}) + klasifikasi.
+type GraphError struct {
+	Op          string `json:"-"`
+	Status      int    `json:"-"`
+	Message     string `json:"message"`
+	Type        string `json:"type"`
+	Code        int    `json:"code"`
+	Subcode     int    `json:"error_subcode"`
+	FBTraceID   string `json:"fbtrace_id"`
+	IsTransient bool   `json:"is_transient"`
+
+	Class ErrorClass `json:"-"`
+}
+
+func (e *GraphError) Error() string {
+	return fmt.Sprintf("%s status %d: %s (type=%s code=%d subcode=%d fbtrace_id=%s class=%s)",
+		e.Op, e.Status, e.Message, e.Type, e.Code, e.Subcode, e.FBTraceID, e.Class)
+}
+
+// Is: hanya invalid parameter (code 100) tanpa subcode pengiriman yang
+// dianggap ErrRejected (payload ditolak); di luar jendela / user tidak
+// tersedia bukan masalah payload.
+func (e *GraphError) Is(target error) bool {
+	return target == ErrRejected && e.Class == ClassPermanent &&
+		e.Code == 100 && !permanentSubcodes[e.Subcode]
+}
+
+// parseGraphError membaca body response gagal menjadi *GraphError.
+func parseGraphError(op string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var env struct {
+		Error *GraphError `json:"error"`
+	}
+	ge := &GraphError{}
+	if json.Unmarshal(body, &env) == nil && env.Error != nil {
+		ge = env.Error
+	} else if len(body) > 0 {
+		ge.Message = string(body)
+	}
+	ge.Op = op
+	ge.Status = resp.StatusCode
+	ge.Class = classify(ge)
+	return ge
+}
+
+// Subcode pesan di luar jendela yang diizinkan / user tidak bisa dihubungi.
+var permanentSubcodes = map[int]bool{
+	2018278: true, // outside allowed window
+	2534022: true, // outside allowed window (IG)
+	2018001: true, // no matching user
+	2018108: true, // user unavailable
+}
+
+// Subcode code 200 yang berarti izin app dicabut di level akun.
+var permissionRevokedSubcodes = map[int]bool{
+	458: true, // app not installed / izin dicabut user akun
+}
+
+// appPermissionLost: hanya kehilangan izin level app yang men-suspend
+// integrasi. Error izin per user/objek (code 10 dengan subcode, code 3,
+// code 200 lain) cukup men-drop task itu saja.
+func appPermissionLost(e *GraphError) bool {
+	switch {
+	case e.Code == 10:
+		return e.Subcode == 0
+	case e.Code == 200:
+		return permissionRevokedSubcodes[e.Subcode]
+	}
+	return false
+}
+
+func classify(e *GraphError) ErrorClass {
+	switch {
+	case permanentSubcodes[e.Subcode]:
+		return ClassPermanent
+	case e.Code == 190 || e.Code == 102 || e.Subcode == 463 || e.Subcode == 467:
+		return ClassTokenInvalid
+	case e.Code == 4 || e.Code == 17 || e.Code == 32 || e.Code == 613 || e.Code == 368 ||
+		(e.Code >= 80001 && e.Code <= 80014) || e.Status == http.StatusTooManyRequests:
+		return ClassRateLimited
+	case appPermissionLost(e):
+		return ClassPermissionDenied
+	case e.Code == 10 || e.Code == 3 || (e.Code >= 200 && e.Code <= 299):
+		return ClassPermanent
+	case e.IsTransient || e.Code == 1 || e.Code == 2 || e.Status >= 500:
+		return ClassRetryable
+	default:
+		return ClassPermanent
+	}
+}
+
+// Classify mengklasifikasi error dari Client. Error non-Graph (network,
+// timeout) dianggap retryable.
+func Classify(err error) ErrorClass {
+	var ge *GraphError
+	if errors.As(err, &ge) {
+		return ge.Class
+	}
+	return ClassRetryable
+}
@@ -0,0 +1,83 @@
+package ig
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseGraphErrorClassification(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   ErrorClass
+	}{
+		{400, `{"error":{"message":"Error validating access token","type":"OAuthException","code":190,"error_subcode":460,"fbtrace_id":"A1"}}`, ClassTokenInvalid},
+		{400, `{"error":{"message":"Application request limit reached","type":"OAuthException","code":4,"is_transient":true}}`, ClassRateLimited},
+		{403, `{"error":{"message":"Permissions error","type":"OAuthException","code":10}}`, ClassPermissionDenied},
+		{400, `{"error":{"message":"outside of allowed window","type":"OAuthException","code":10,"error_subcode":2534022}}`, ClassPermanent},
+		{500, `{"error":{"message":"An unknown error occurred","type":"OAuthException","code":1,"is_transient":true}}`, ClassRetryable},
+		{400, `{"error":{"message":"Invalid parameter","type":"OAuthException","code":100}}`, ClassPermanent},
+		{502, `<html>bad gateway</html>`, ClassRetryable},
+	}
+	for _, c := range cases {
+		resp := &http.Response{StatusCode: c.status, Body: io.NopCloser(strings.NewReader(c.body))}
+		err := parseGraphError("SendMessage", resp)
+		if got := Classify(err); got != c.want {
+			t.Fatalf("body %s: class %q, want %q", c.body, got, c.want)
+		}
+	}
+}
+
+func TestPermissionErrorsSuspendOnlyAppLevel(t *testing.T) {
+	cases := []struct {
+		code, subcode int
+		want          ErrorClass
+	}{
+		{10, 0, ClassPermissionDenied},
+		{200, 458, ClassPermissionDenied},
+		{10, 2018108, ClassPermanent}, // user tidak tersedia
+		{10, 2018065, ClassPermanent},
+		{3, 0, ClassPermanent},
+		{200, 0, ClassPermanent},
+		{230, 0, ClassPermanent},
+	}
+	for _, c := range cases {
+		if got := classify(&GraphError{Status: 403, Code: c.code, Subcode: c.subcode}); got != c.want {
+			t.Fatalf("code %d/%d: class %q, want %q", c.code, c.subcode, got, c.want)
+		}
+	}
+}
+
+func TestErrRejectedOnlyInvalidParameter(t *testing.T) {
+	cases := []struct {
+		code, subcode int
+		want          bool
+	}{
+		{100, 0, true},
+		{100, 2018001, false}, // no matching user
+		{10, 2534022, false},  // outside allowed window
+		{230, 0, false},
+	}
+	for _, c := range cases {
+		ge := &GraphError{Status: 400, Code: c.code, Subcode: c.subcode}
+		ge.Class = classify(ge)
+		if got := errors.Is(ge, ErrRejected); got != c.want {
+			t.Fatalf("code %d/%d: ErrRejected %v, want %v", c.code, c.subcode, got, c.want)
+		}
+	}
+}
+
+func TestParseGraphErrorFields(t *testing.T) {
+	body := `{"error":{"message":"Invalid OAuth access token.","type":"OAuthException","code":190,"error_subcode":463,"fbtrace_id":"Abc123","is_transient":false}}`
+	resp := &http.Response{StatusCode: 400, Body: io.NopCloser(strings.NewReader(body))}
+	var ge *GraphError
+	if !errors.As(parseGraphError("ReplyComment", resp), &ge) {
+		t.Fatalf("expected *GraphError")
+	}
+	if ge.Code != 190 || ge.Subcode != 463 || ge.FBTraceID != "Abc123" || ge.Type != "OAuthException" || ge.Status != 400 {
+		t.Fatalf("unexpected fields %+v", ge)
+	}
+}
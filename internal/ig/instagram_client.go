@@ -15,18 +15,38 @@ type Client struct {
 	APIToken   string // page access token (per brand)
 	APIVersion string // e.g. v21.0
 	BaseURL    string
+	// OAuthBaseURL: endpoint tukar authorization code (api.instagram.com)
+	OAuthBaseURL string
+
+	// OnUsage dipanggil untuk setiap response yang membawa header usage
+	OnUsage func(Usage)
 }
 
 func NewClient(apiToken string) *Client {
 	return &Client{
-		HTTP:       &http.Client{Timeout: 10 * time.Second},
-		APIToken:   apiToken,
-		APIVersion: "v21.0",
-		BaseURL:    "https://graph.instagram.com"}
+		HTTP:         &http.Client{Timeout: 10 * time.Second},
+		APIToken:     apiToken,
+		APIVersion:   "v21.0",
+		BaseURL:      "https://graph.instagram.com",
+		OAuthBaseURL: "https://api.instagram.com"}
+}
+
+// do menjalankan request dan melaporkan header usage ke OnUsage.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if c.OnUsage != nil {
+		if u := ParseUsage(resp.Header); u != nil {
+			c.OnUsage(*u)
+		}
+	}
+	return resp, nil
 }
 
 // Post Comment Reply (public)
-func (c *Client) ReplyComment(ctx context.Context, commentID, message string) error {
+func (c *Client) ReplyComment(ctx context.Context, commentID, message string) (string, error) {
 	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/replies", c.APIVersion, commentID)
 	body := map[string]string{
 		"message":      message,
@@ -35,41 +55,39 @@ func (c *Client) ReplyComment(ctx context.Context, commentID, message string) er
 	b, _ := json.Marshal(body)
 	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := c.HTTP.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("ReplyComment status %d", resp.StatusCode)
+		return "", parseGraphError("ReplyComment", resp)
+	}
+	// id reply dipakai untuk mengenali thread balasan ke komentar brand
+	var out struct {
+		ID string `json:"id"`
 	}
-	return nil
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return out.ID, nil
 }
 
 // Send DM (Instagram messaging API via FB Graph)
-// NOTE: DM API punya batasan; ini contoh pseudo endpoint, sesuaikan dgn endpoint real & permission.
-// Untuk MVP, kirim link sebagai teks.
+// NOTE: DM ke IG user id hanya bisa kalau user pernah mengirim pesan ke bisnis;
+// untuk DM dari komentar pakai SendPrivateReply.
 func (c *Client) SendDM(ctx context.Context, recipientIGUserID, message string) error {
-	url := fmt.Sprintf("https://graph.facebook.com/%s/me/messages", c.APIVersion)
-	body := map[string]interface{}{
-		"recipient":    map[string]string{"id": recipientIGUserID},
-		"message":      map[string]string{"text": message},
-		"access_token": c.APIToken,
-	}
-	b, _ := json.Marshal(body)
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := c.HTTP.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		return fmt.Errorf("SendDM status %d", resp.StatusCode)
-	}
-	return nil
+	return c.SendMessage(ctx, Recipient{ID: recipientIGUserID}, TextMessage(message))
+}
+
+// SendPrivateReply mengirim DM ke komentator lewat Private Replies
+// (recipient.comment_id). Meta hanya mengizinkan satu private reply per
+// komentar, maksimal 7 hari setelah komentar dibuat.
+func (c *Client) SendPrivateReply(ctx context.Context, commentID, message string) error {
+	return c.SendMessage(ctx, Recipient{CommentID: commentID}, TextMessage(message))
 }
 
+// PrivateReplyWindow: batas umur komentar untuk private reply.
+const PrivateReplyWindow = 7 * 24 * time.Hour
+
 type TokenResponse struct {
 	AccessToken string `json:"access_token"`
 	TokenType   string `json:"token_type"`
@@ -86,15 +104,14 @@ func (c *Client) RefreshLongLivedToken(ctx context.Context, accessToken string)
 	u.RawQuery = q.Encode()
 
 	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	resp, err := c.HTTP.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("refresh call: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		// optional: decode meta error body to log
-		return nil, fmt.Errorf("refresh status %d", resp.StatusCode)
+		return nil, parseGraphError("RefreshLongLivedToken", resp)
 	}
 
 	var tr TokenResponse
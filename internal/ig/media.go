@@ -0,0 +1,90 @@
+package ig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type Media struct {
+	ID               string    `json:"id"`
+	Permalink        string    `json:"permalink"`
+	Shortcode        string    `json:"shortcode"`
+	Caption          string    `json:"caption"`
+	MediaType        string    `json:"media_type"`         // IMAGE | VIDEO | CAROUSEL_ALBUM
+	MediaProductType string    `json:"media_product_type"` // FEED | REELS | STORY | AD
+	Timestamp        GraphTime `json:"timestamp"`
+}
+
+type MediaPage struct {
+	Media []Media
+	After string // cursor halaman berikutnya; kosong = habis
+}
+
+const mediaFields = "id,permalink,shortcode,caption,media_type,media_product_type,timestamp"
+
+// ListMedia: GET {BaseURL}/{ver}/me/media (terbaru dulu).
+func (c *Client) ListMedia(ctx context.Context, after string, limit int) (*MediaPage, error) {
+	u, _ := url.Parse(fmt.Sprintf("%s/%s/me/media", c.BaseURL, c.APIVersion))
+	q := u.Query()
+	q.Set("fields", mediaFields)
+	q.Set("limit", fmt.Sprint(limit))
+	q.Set("access_token", c.APIToken)
+	if after != "" {
+		q.Set("after", after)
+	}
+	u.RawQuery = q.Encode()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list media: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, parseGraphError("ListMedia", resp)
+	}
+	var out struct {
+		Data   []Media `json:"data"`
+		Paging struct {
+			Cursors struct {
+				After string `json:"after"`
+			} `json:"cursors"`
+			Next string `json:"next"`
+		} `json:"paging"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	page := &MediaPage{Media: out.Data}
+	if out.Paging.Next != "" {
+		page.After = out.Paging.Cursors.After
+	}
+	return page, nil
+}
+
+// GetMedia: GET {BaseURL}/{ver}/{media-id}
+func (c *Client) GetMedia(ctx context.Context, mediaID string) (*Media, error) {
+	u, _ := url.Parse(fmt.Sprintf("%s/%s/%s", c.BaseURL, c.APIVersion, mediaID))
+	q := u.Query()
+	q.Set("fields", mediaFields)
+	q.Set("access_token", c.APIToken)
+	u.RawQuery = q.Encode()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get media: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, parseGraphError("GetMedia", resp)
+	}
+	var m Media
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return &m, nil
+}
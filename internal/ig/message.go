@@ -0,0 +1,130 @@
+package ig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrRejected: Graph API menolak payload secara permanen (invalid parameter,
+// code 100), mis. template tidak didukung. Caller bisa fallback ke teks.
+var ErrRejected = errors.New("message rejected")
+
+// Recipient: isi salah satu. CommentID = private reply ke komentar.
+type Recipient struct {
+	ID        string `json:"id,omitempty"`
+	CommentID string `json:"comment_id,omitempty"`
+}
+
+type QuickReply struct {
+	ContentType string `json:"content_type"` // "text"
+	Title       string `json:"title"`
+	Payload     string `json:"payload"`
+}
+
+type Button struct {
+	Type    string `json:"type"` // "web_url" | "postback"
+	Title   string `json:"title"`
+	URL     string `json:"url,omitempty"`
+	Payload string `json:"payload,omitempty"`
+}
+
+type GenericElement struct {
+	Title    string   `json:"title"`
+	Subtitle string   `json:"subtitle,omitempty"`
+	ImageURL string   `json:"image_url,omitempty"`
+	Buttons  []Button `json:"buttons,omitempty"`
+}
+
+type AttachmentPayload struct {
+	TemplateType string           `json:"template_type,omitempty"` // "button" | "generic"
+	Text         string           `json:"text,omitempty"`
+	Buttons      []Button         `json:"buttons,omitempty"`
+	Elements     []GenericElement `json:"elements,omitempty"`
+	URL          string           `json:"url,omitempty"` // media
+}
+
+type Attachment struct {
+	Type    string            `json:"type"` // "template" | "image" | "video"
+	Payload AttachmentPayload `json:"payload"`
+}
+
+// Message: teks, atau satu attachment (template/media), plus quick replies opsional.
+type Message struct {
+	Text         string       `json:"text,omitempty"`
+	Attachment   *Attachment  `json:"attachment,omitempty"`
+	QuickReplies []QuickReply `json:"quick_replies,omitempty"`
+}
+
+// Batas Messenger Platform
+const (
+	maxTemplateButtons = 3
+	maxQuickReplies    = 13
+)
+
+func TextMessage(text string) Message {
+	return Message{Text: text}
+}
+
+// ButtonTemplate: teks + maksimal 3 tombol.
+func ButtonTemplate(text string, buttons []Button) Message {
+	if len(buttons) > maxTemplateButtons {
+		buttons = buttons[:maxTemplateButtons]
+	}
+	return Message{Attachment: &Attachment{
+		Type:    "template",
+		Payload: AttachmentPayload{TemplateType: "button", Text: text, Buttons: buttons},
+	}}
+}
+
+// GenericTemplate: kartu dengan gambar, judul dan tombol.
+func GenericTemplate(elements ...GenericElement) Message {
+	for i := range elements {
+		if len(elements[i].Buttons) > maxTemplateButtons {
+			elements[i].Buttons = elements[i].Buttons[:maxTemplateButtons]
+		}
+	}
+	return Message{Attachment: &Attachment{
+		Type:    "template",
+		Payload: AttachmentPayload{TemplateType: "generic", Elements: elements},
+	}}
+}
+
+// MediaMessage: kind "image" | "video".
+func MediaMessage(kind, url string) Message {
+	return Message{Attachment: &Attachment{Type: kind, Payload: AttachmentPayload{URL: url}}}
+}
+
+// WithQuickReplies menempelkan quick replies (maksimal 13).
+func (m Message) WithQuickReplies(qr []QuickReply) Message {
+	if len(qr) > maxQuickReplies {
+		qr = qr[:maxQuickReplies]
+	}
+	m.QuickReplies = qr
+	return m
+}
+
+// SendMessage mengirim pesan terstruktur ke recipient.
+func (c *Client) SendMessage(ctx context.Context, to Recipient, msg Message) error {
+	url := fmt.Sprintf("https://graph.facebook.com/%s/me/messages", c.APIVersion)
+	body := map[string]interface{}{
+		"recipient":    to,
+		"message":      msg,
+		"access_token": c.APIToken,
+	}
+	b, _ := json.Marshal(body)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return parseGraphError("SendMessage", resp)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package ig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Instagram API with Instagram Login (business login).
+const authorizeEndpoint = "https://www.instagram.com/oauth/authorize"
+
+// DefaultOAuthScopes: baca profil, kelola komentar & pesan.
+var DefaultOAuthScopes = []string{
+	"instagram_business_basic",
+	"instagram_business_manage_comments",
+	"instagram_business_manage_messages",
+}
+
+type OAuthConfig struct {
+	AppID       string
+	AppSecret   string
+	RedirectURI string
+	Scopes      []string
+}
+
+// AuthorizeURL: halaman consent Instagram; state dikembalikan ke callback.
+func (o OAuthConfig) AuthorizeURL(state string) string {
+	scopes := o.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultOAuthScopes
+	}
+	q := url.Values{}
+	q.Set("client_id", o.AppID)
+	q.Set("redirect_uri", o.RedirectURI)
+	q.Set("response_type", "code")
+	q.Set("scope", strings.Join(scopes, ","))
+	q.Set("state", state)
+	return authorizeEndpoint + "?" + q.Encode()
+}
+
+// ShortLivedToken: hasil tukar authorization code (berlaku ±1 jam).
+type ShortLivedToken struct {
+	AccessToken string `json:"access_token"`
+	UserID      string `json:"user_id"`
+	Permissions string `json:"permissions"`
+}
+
+// ExchangeCode menukar authorization code dengan short-lived token.
+// POST {OAuthBaseURL}/oauth/access_token (form-encoded).
+func (c *Client) ExchangeCode(ctx context.Context, o OAuthConfig, code string) (*ShortLivedToken, error) {
+	form := url.Values{}
+	form.Set("client_id", o.AppID)
+	form.Set("client_secret", o.AppSecret)
+	form.Set("grant_type", "authorization_code")
+	form.Set("redirect_uri", o.RedirectURI)
+	form.Set("code", code)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.OAuthBaseURL+"/oauth/access_token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, parseGraphError("ExchangeCode", resp)
+	}
+
+	// user_id bisa berupa number; permissions bisa string atau array
+	var raw struct {
+		AccessToken string          `json:"access_token"`
+		UserID      json.Number     `json:"user_id"`
+		Permissions json.RawMessage `json:"permissions"`
+		Data        []struct {
+			AccessToken string          `json:"access_token"`
+			UserID      json.Number     `json:"user_id"`
+			Permissions json.RawMessage `json:"permissions"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	if raw.AccessToken == "" && len(raw.Data) > 0 {
+		raw.AccessToken, raw.UserID, raw.Permissions = raw.Data[0].AccessToken, raw.Data[0].UserID, raw.Data[0].Permissions
+	}
+	if raw.AccessToken == "" {
+		return nil, fmt.Errorf("exchange code: empty access_token")
+	}
+	return &ShortLivedToken{
+		AccessToken: raw.AccessToken,
+		UserID:      raw.UserID.String(),
+		Permissions: permissionsString(raw.Permissions),
+	}, nil
+}
+
+func permissionsString(raw json.RawMessage) string {
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		return strings.Join(list, ",")
+	}
+	var s string
+	_ = json.Unmarshal(raw, &s)
+	return s
+}
+
+// ExchangeLongLivedToken menukar short-lived token dengan long-lived token (±60 hari).
+// GET {BaseURL}/access_token?grant_type=ig_exchange_token
+func (c *Client) ExchangeLongLivedToken(ctx context.Context, appSecret, shortToken string) (*TokenResponse, error) {
+	u, _ := url.Parse(c.BaseURL + "/access_token")
+	q := u.Query()
+	q.Set("grant_type", "ig_exchange_token")
+	q.Set("client_secret", appSecret)
+	q.Set("access_token", shortToken)
+	u.RawQuery = q.Encode()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("exchange long-lived: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, parseGraphError("ExchangeLongLivedToken", resp)
+	}
+	var tr TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return &tr, nil
+}
+
+// Profile: akun IG professional pemilik token. UserID = IG business account
+// id yang dipakai di webhook (entry.id) dan integration.account_id.
+type Profile struct {
+	UserID            string `json:"user_id"`
+	Username          string `json:"username"`
+	Name              string `json:"name"`
+	AccountType       string `json:"account_type"`
+	ProfilePictureURL string `json:"profile_picture_url"`
+}
+
+// GetProfile: GET {BaseURL}/{ver}/me
+func (c *Client) GetProfile(ctx context.Context, accessToken string) (*Profile, error) {
+	u, _ := url.Parse(fmt.Sprintf("%s/%s/me", c.BaseURL, c.APIVersion))
+	q := u.Query()
+	q.Set("fields", "user_id,username,name,account_type,profile_picture_url")
+	q.Set("access_token", accessToken)
+	u.RawQuery = q.Encode()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get profile: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, parseGraphError("GetProfile", resp)
+	}
+	var raw struct {
+		Profile
+		UserID json.Number `json:"user_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	p := raw.Profile
+	p.UserID = raw.UserID.String()
+	if p.UserID == "" {
+		return nil, fmt.Errorf("get profile: empty user_id")
+	}
+	return &p, nil
+}
@@ -0,0 +1,93 @@
+package ig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultWebhookFields: field webhook yang dibutuhkan service ini.
+var DefaultWebhookFields = []string{"comments", "messages", "mentions", "messaging_postbacks"}
+
+func (c *Client) subscribedAppsURL(params url.Values) string {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("access_token", c.APIToken)
+	return fmt.Sprintf("%s/%s/me/subscribed_apps?%s", c.BaseURL, c.APIVersion, params.Encode())
+}
+
+// SubscribedFields: field webhook yang sedang aktif untuk akun pemilik token.
+func (c *Client) SubscribedFields(ctx context.Context) ([]string, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.subscribedAppsURL(nil), nil)
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list subscribed apps: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, parseGraphError("SubscribedFields", resp)
+	}
+	var out struct {
+		Data []struct {
+			SubscribedFields []string `json:"subscribed_fields"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	var fields []string
+	for _, d := range out.Data {
+		fields = append(fields, d.SubscribedFields...)
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// Subscribe mengganti field webhook akun dengan fields.
+func (c *Client) Subscribe(ctx context.Context, fields []string) error {
+	u := c.subscribedAppsURL(url.Values{"subscribed_fields": {strings.Join(fields, ",")}})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return parseGraphError("Subscribe", resp)
+	}
+	return nil
+}
+
+// Unsubscribe menghentikan semua webhook untuk akun.
+func (c *Client) Unsubscribe(ctx context.Context) error {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodDelete, c.subscribedAppsURL(nil), nil)
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("unsubscribe: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return parseGraphError("Unsubscribe", resp)
+	}
+	return nil
+}
+
+// MissingFields: field di want yang tidak ada di have.
+func MissingFields(have, want []string) []string {
+	set := make(map[string]bool, len(have))
+	for _, f := range have {
+		set[f] = true
+	}
+	var missing []string
+	for _, f := range want {
+		if !set[f] {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,67 @@
+package ig
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// AppUsage: header X-App-Usage (persentase 0-100).
+type AppUsage struct {
+	CallCount    int `json:"call_count"`
+	TotalCPUTime int `json:"total_cputime"`
+	TotalTime    int `json:"total_time"`
+}
+
+// BUCUsage: satu entri header X-Business-Use-Case-Usage.
+type BUCUsage struct {
+	Type                        string `json:"type"`
+	CallCount                   int    `json:"call_count"`
+	TotalCPUTime                int    `json:"total_cputime"`
+	TotalTime                   int    `json:"total_time"`
+	EstimatedTimeToRegainAccess int    `json:"estimated_time_to_regain_access"` // menit
+}
+
+// Usage: pemakaian kuota dari header response Graph API.
+type Usage struct {
+	App      *AppUsage
+	Business map[string][]BUCUsage // key = business object id (IG account)
+}
+
+func (a AppUsage) Percent() int {
+	return maxInt(a.CallCount, a.TotalCPUTime, a.TotalTime)
+}
+
+func (b BUCUsage) Percent() int {
+	return maxInt(b.CallCount, b.TotalCPUTime, b.TotalTime)
+}
+
+// ParseUsage membaca header usage; nil kalau tidak ada.
+func ParseUsage(h http.Header) *Usage {
+	var u Usage
+	if v := h.Get("X-App-Usage"); v != "" {
+		var a AppUsage
+		if json.Unmarshal([]byte(v), &a) == nil {
+			u.App = &a
+		}
+	}
+	if v := h.Get("X-Business-Use-Case-Usage"); v != "" {
+		var b map[string][]BUCUsage
+		if json.Unmarshal([]byte(v), &b) == nil && len(b) > 0 {
+			u.Business = b
+		}
+	}
+	if u.App == nil && u.Business == nil {
+		return nil
+	}
+	return &u
+}
+
+func maxInt(v ...int) int {
+	m := 0
+	for _, x := range v {
+		if x > m {
+			m = x
+		}
+	}
+	return m
+}
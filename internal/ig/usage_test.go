@@ -0,0 +1,23 @@
+package ig
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseUsage(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-App-Usage", `{"call_count":12,"total_cputime":40,"total_time":9}`)
+	h.Set("X-Business-Use-Case-Usage", `{"1784":[{"type":"instagram","call_count":100,"total_cputime":3,"total_time":5,"estimated_time_to_regain_access":17}]}`)
+	u := ParseUsage(h)
+	if u == nil || u.App == nil || u.App.Percent() != 40 {
+		t.Fatalf("unexpected app usage %+v", u)
+	}
+	b := u.Business["1784"]
+	if len(b) != 1 || b[0].Percent() != 100 || b[0].EstimatedTimeToRegainAccess != 17 {
+		t.Fatalf("unexpected business usage %+v", b)
+	}
+	if ParseUsage(http.Header{}) != nil {
+		t.Fatalf("expected nil without usage headers")
+	}
+}
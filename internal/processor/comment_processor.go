@@ -3,12 +3,15 @@ package processor
 import (
 	"context"
 	"encoding/json"
+	"ig-webhook/internal/ig"
 	"ig-webhook/internal/queue"
+	"ig-webhook/internal/rate"
 	"ig-webhook/internal/repo"
 	"ig-webhook/internal/store"
 	"ig-webhook/internal/types"
 	"log"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,25 +19,75 @@ import (
 )
 
 type CommentEvent struct {
-	EventID       string // unique id dari IG webhook (atau gabungan: comment_id + timestamp)
-	BrandID       string // tenant/brand internal ID
-	IGBusinessID  string // IG business account id
-	CommentID     string
-	PostID        string
-	Text          string
-	FromIGUserID  string
-	FromUsername  string
-	IGAccessToken string // token spesifik brand
+	EventID      string // unique id dari IG webhook (atau gabungan: comment_id + timestamp)
+	BrandID      string // tenant/brand internal ID
+	IGBusinessID string // IG business account id
+	CommentID    string
+	PostID       string
+	// ParentCommentID terisi kalau komentar adalah balasan di thread
+	ParentCommentID string
+	// AdID/AdTitle terisi untuk komentar di iklan (kosong = organik)
+	AdID         string
+	AdTitle      string
+	Text         string
+	FromIGUserID string
+	FromUsername string
+	CommentedAt  time.Time
+	// Reconciled: komentar dari polling rekonsiliasi, atribusi iklan tidak diketahui
+	Reconciled bool
 }
 
 type WorkflowRepo interface {
 	repo.WorkflowRepo
 }
 
+// MediaCatalog: katalog media akun (service.MediaCatalog) untuk resolve
+// permalink/shortcode dan metadata targeting post.
+type MediaCatalog interface {
+	ResolveAll(ctx context.Context, igBusinessID string, refs []string) ([]string, map[string]error)
+	Media(ctx context.Context, igBusinessID, mediaID string) (*repo.MediaRow, error)
+	FirstMediaAfter(ctx context.Context, igBusinessID string, t time.Time) (*repo.MediaRow, error)
+}
+
+// CommentLookup mengambil komentar by id (service.CommentLookup).
+type CommentLookup interface {
+	Comment(ctx context.Context, igBusinessID, commentID string) (*ig.Comment, error)
+}
+
+// CommentStats mencatat analytics komentar organik vs iklan (service.CommentStats).
+type CommentStats interface {
+	RecordComment(ctx context.Context, ev CommentEvent) error
+	RecordTriggered(ctx context.Context, ev CommentEvent) error
+}
+
+// Komentar milik brand (termasuk reply bot) ditandai supaya balasan ke
+// komentar tersebut bisa dikenali tanpa memanggil Graph API.
+const OwnCommentTTL = 30 * 24 * time.Hour
+
+func OwnCommentKey(igBusinessID, commentID string) string {
+	return "ig:own_comment:" + igBusinessID + ":" + commentID
+}
+
 type CommentProcessor struct {
 	kv *store.RedisStore
 	q  *asynq.Client
 	db WorkflowRepo
+
+	// DefaultTimezone dipakai kalau safety workflow tidak menyetel timezone
+	DefaultTimezone string
+
+	// Status integrasi; event untuk integrasi non-aktif tidak diproses (nil = tidak dicek)
+	Status *repo.IntegrationStatusLookup
+
+	// Media me-resolve SelectedPostID berupa permalink/shortcode dan menyediakan
+	// metadata untuk mode targeting (nil = hanya media id, mode berbasis metadata tidak jalan)
+	Media MediaCatalog
+
+	// Comments mengambil parent komentar (cek author & template parent); nil = hanya marker Redis
+	Comments CommentLookup
+
+	// Stats analytics komentar (nil = tidak dicatat)
+	Stats CommentStats
 }
 
 func NewCommentProcessor(kv *store.RedisStore, q *asynq.Client, db WorkflowRepo) *CommentProcessor {
@@ -52,33 +105,74 @@ func (p *CommentProcessor) Process(ctx context.Context, ev CommentEvent) error {
 		return nil
 	}
 
+	// Komentar brand sendiri: tandai (untuk scope replies_to_brand), jangan dibalas
+	if ev.FromIGUserID != "" && ev.FromIGUserID == ev.IGBusinessID {
+		if err := p.kv.Set(ctx, OwnCommentKey(ev.IGBusinessID, ev.CommentID), "1", OwnCommentTTL); err != nil {
+			log.Printf("[WARN] mark own comment %s: %v", ev.CommentID, err)
+		}
+		log.Printf("[SKIP] own comment acct=%s comment=%s", ev.IGBusinessID, ev.CommentID)
+		return nil
+	}
+
+	if p.Stats != nil {
+		if err := p.Stats.RecordComment(ctx, ev); err != nil {
+			log.Printf("[WARN] comment stats: %v", err)
+		}
+	}
+
+	// Integrasi needs_reauth/suspended: jangan enqueue aksi yang pasti gagal
+	if p.Status != nil {
+		status, err := p.Status.Status(ctx, ev.IGBusinessID)
+		if err != nil {
+			log.Printf("[WARN] integration status acct=%s: %v", ev.IGBusinessID, err)
+		} else if !status.Usable() {
+			log.Printf("[SKIP] integration %s acct=%s event=%s", status, ev.IGBusinessID, ev.EventID)
+			return nil
+		}
+	}
+
 	// Ambil workflows
 	wfs, err := p.db.ListActiveWorkflowsForIGAccount(ev.IGBusinessID)
 	if err != nil {
 		return err
 	}
 
-	for _, wf := range wfs {
-		// Cari trigger node IG_COMMENT_RECEIVED
-		var trig types.Node
-		for _, n := range wf.Nodes {
-			// n.Data["type"] di JSON menyimpan trigger type
-			if t, _ := n.Data["type"].(string); t == string(types.TriggerIGCommentReceived) {
-				trig = n
-				break
-			}
+	// minimal satu aksi di-enqueue (analytics)
+	triggered := false
+	markTriggered := func() {
+		if triggered || p.Stats == nil {
+			return
 		}
-		if trig.ID == "" {
+		triggered = true
+		if err := p.Stats.RecordTriggered(ctx, ev); err != nil {
+			log.Printf("[WARN] comment stats: %v", err)
+		}
+	}
+
+	// parent thread diambil sekali per event, hanya kalau dibutuhkan
+	var parent *ig.Comment
+	parentLoaded := false
+	loadParent := func() *ig.Comment {
+		if !parentLoaded {
+			parentLoaded = true
+			parent = p.parentComment(ctx, ev)
+		}
+		return parent
+	}
+
+	for _, wf := range wfs {
+		trig, cfg, ok := commentTrigger(wf)
+		if !ok {
 			continue
 		}
 
-		// Parse igUserCommentData
-		b, _ := json.Marshal(trig.Data["igUserCommentData"])
-		var cfg types.IGUserCommentData
-		_ = json.Unmarshal(b, &cfg)
+		// Scope thread: top-level / balasan / balasan ke komentar brand
+		if !p.replyScopeMatches(ctx, ev, cfg.ReplyScope, loadParent) {
+			continue
+		}
 
-		// Post filter
-		if !contains(cfg.SelectedPostID, ev.PostID) {
+		// Post filter (mode targeting)
+		if !p.postTargeted(ctx, wf.ID, ev, cfg) {
 			continue
 		}
 
@@ -104,6 +198,21 @@ func (p *CommentProcessor) Process(ctx context.Context, ev CommentEvent) error {
 			continue
 		}
 
+		// Parse IG_SEND_MSG: aksi yang semua action type-nya nonaktif dilewati
+		// sebelum klaim idem:exec, supaya komentar ini masih bisa diproses
+		// (mis. rekonsiliasi) setelah aksi diaktifkan lagi
+		isSendMsg := false
+		var rd types.IGReplyData
+		if t, _ := actionNode.Data["type"].(string); t == string(types.ActionIGSendMsg) {
+			isSendMsg = true
+			b2, _ := json.Marshal(actionNode.Data["igReplyData"])
+			_ = json.Unmarshal(b2, &rd)
+			if at := rd.Safety.ActionTypes; !at.EnableCommentReply && !at.EnableDMReply {
+				log.Printf("[SKIP] all actions disabled wf=%s node=%s", wf.ID, actionNode.ID)
+				continue
+			}
+		}
+
 		// Idempotensi node execution
 		execKey := "idem:exec:" + wf.ID + ":" + actionNode.ID + ":" + ev.CommentID
 		ok, err := p.kv.AcquireOnce(ctx, execKey, 7*24*time.Hour)
@@ -115,61 +224,462 @@ func (p *CommentProcessor) Process(ctx context.Context, ev CommentEvent) error {
 			continue
 		}
 
-		// Parse IG_SEND_MSG
-		if t, _ := actionNode.Data["type"].(string); t == string(types.ActionIGSendMsg) {
-			// igReplyData
-			b2, _ := json.Marshal(actionNode.Data["igReplyData"])
-			var rd types.IGReplyData
-			_ = json.Unmarshal(b2, &rd)
+		if isSendMsg {
+			// Template pesan: {{username}}, {{comment}}, {{parent.*}}
+			var tplParent *ig.Comment
+			if usesParent(append([]string{rd.DMMessage}, rd.PublicReplies...)...) {
+				tplParent = loadParent()
+			}
+			vars := templateVars(ev, tplParent)
+			rd.DMMessage = renderTemplate(rd.DMMessage, vars)
 
-			// Safety
-			limits := rd.Safety.CombinedLimits
+			// Safety: isi limit/delay kosong dari preset mode
+			safety := rd.Safety.Effective()
+			limits := safety.CombinedLimits
 			delayBetween := queue.RandDelaySec(limits.DelayBetweenActions[0], limits.DelayBetweenActions[1])
-			commentToDm := queue.RandDelaySec(limits.CommentToDmDelay[0], limits.CommentToDmDelay[1])
 
-			// Pick public reply (random/round-robin; di sini ambil index by hash)
-			msg := pickOne(rd.PublicReplies, ev.FromIGUserID)
-
-			// Enqueue public reply
-			pubPayload := queue.TaskSendPublicReplyPayload{
-				BrandID:    ev.BrandID,
-				CommentID:  ev.CommentID,
-				Message:    sanitizePublicMessage(msg, rd.Safety.ContentRules),
-				IGToken:    ev.IGAccessToken,
-				WorkflowID: wf.ID,
-				NodeID:     actionNode.ID,
+			// Jadwal geser keluar dari quiet hours (waktu lokal brand)
+			tz := safety.Timezone
+			if tz == "" {
+				tz = p.DefaultTimezone
 			}
-			taskA, optsA := queue.NewPublicReplyTask(pubPayload, delayBetween)
-			if _, err := p.q.EnqueueContext(ctx, taskA, optsA...); err != nil {
-				return err
+			var quietStart, quietEnd string
+			if safety.QuietHours != nil {
+				quietStart, quietEnd = safety.QuietHours.Start, safety.QuietHours.End
 			}
+			quiet, err := rate.ParseQuietHours(quietStart, quietEnd, rate.LoadLocation(tz))
+			if err != nil {
+				log.Printf("[WARN] invalid quiet hours wf=%s: %v", wf.ID, err)
+			}
+			now := time.Now()
+
+			// DM payload (dikirim berantai setelah public reply, atau langsung)
+			var dmPayload *queue.TaskSendDMPayload
+			if safety.ActionTypes.EnableDMReply {
+				// Compose DM message + tombol (render sederhana jadi teks)
+				dmText := rd.DMMessage
+				for _, btn := range rd.Buttons {
+					if btn.Enabled && btn.URL != "" {
+						dmText += "\n" + btn.Title + ": " + btn.URL
+					}
+				}
 
-			// Compose DM message + tombol (render sederhana jadi teks)
-			dmText := rd.DMMessage
-			for _, btn := range rd.Buttons {
-				if btn.Enabled && btn.URL != "" {
-					dmText += "\n" + btn.Title + ": " + btn.URL
+				dmPayload = &queue.TaskSendDMPayload{
+					BrandID:           ev.BrandID,
+					IGBusinessID:      ev.IGBusinessID,
+					RecipientIGUserID: ev.FromIGUserID,
+					CommentID:         ev.CommentID,
+					CommentedAt:       ev.CommentedAt,
+					PostID:            ev.PostID,
+					Message:           dmText,
+					WorkflowID:        wf.ID,
+					NodeID:            actionNode.ID,
+					MaxPerHour:        limits.MaxActionsPerHour,
+					MaxPerDay:         limits.MaxActionsPerDay,
+					SafetyDisabled:    !safety.Enabled,
+					CreatedAt:         now.UTC(),
+					Timezone:          tz,
+					QuietStart:        quietStart,
+					QuietEnd:          quietEnd,
+					CooldownScope:     safety.Cooldown.Scope,
+					CooldownMinutes:   safety.Cooldown.DurationMinutes,
 				}
+				dmPayload.Rich = buildRichDM(rd)
 			}
 
-			// Enqueue DM (depends on commentToDmDelay)
-			dmPayload := queue.TaskSendDMPayload{
-				BrandID:           ev.BrandID,
-				RecipientIGUserID: ev.FromIGUserID,
-				Message:           dmText,
-				IGToken:           ev.IGAccessToken,
-				WorkflowID:        wf.ID,
-				NodeID:            actionNode.ID,
+			if safety.ActionTypes.EnableCommentReply {
+				// Pick public reply (random/round-robin; di sini ambil index by hash)
+				msg := renderTemplate(pickOne(rd.PublicReplies, ev.FromIGUserID), vars)
+
+				// Enqueue public reply; DM ikut di payload dan di-enqueue oleh handler-nya
+				pubPayload := queue.TaskSendPublicReplyPayload{
+					BrandID:        ev.BrandID,
+					IGBusinessID:   ev.IGBusinessID,
+					CommentID:      ev.CommentID,
+					PostID:         ev.PostID,
+					FromIGUserID:   ev.FromIGUserID,
+					Message:        sanitizePublicMessage(msg, safety.ContentRules),
+					WorkflowID:     wf.ID,
+					NodeID:         actionNode.ID,
+					MaxPerHour:     limits.MaxActionsPerHour,
+					MaxPerDay:      limits.MaxActionsPerDay,
+					SafetyDisabled: !safety.Enabled,
+					CreatedAt:      now.UTC(),
+					Timezone:       tz,
+					QuietStart:     quietStart,
+					QuietEnd:       quietEnd,
+
+					CooldownScope:       safety.Cooldown.Scope,
+					CooldownMinutes:     safety.Cooldown.DurationMinutes,
+					SuppressPublicReply: safety.Cooldown.SuppressPublicReply,
+
+					NextDM:           dmPayload,
+					CommentToDmDelay: limits.CommentToDmDelay,
+					DMOnReplyFailure: safety.ActionTypes.DMOnReplyFailure,
+				}
+				runAt := quiet.Next(now.Add(delayBetween), quietJitter)
+				taskA, optsA := queue.NewPublicReplyTask(pubPayload, runAt.Sub(now))
+				if _, err := p.q.EnqueueContext(ctx, taskA, optsA...); err != nil {
+					return err
+				}
+				markTriggered()
+				continue
 			}
-			taskB, optsB := queue.NewDMTask(dmPayload, commentToDm+delayBetween)
+
+			// Public reply mati: DM langsung, delay commentToDm dari komentar
+			commentToDm := queue.RandDelaySec(limits.CommentToDmDelay[0], limits.CommentToDmDelay[1])
+			dmAt := quiet.Next(now.Add(commentToDm), quietJitter)
+			taskB, optsB := queue.NewDMTask(*dmPayload, dmAt.Sub(now))
 			if _, err := p.q.EnqueueContext(ctx, taskB, optsB...); err != nil {
 				return err
 			}
+			markTriggered()
 		}
 	}
 	return nil
 }
 
+// jitter saat aksi digeser ke akhir quiet hours
+const quietJitter = 15 * time.Minute
+
+// Batas judul & subjudul generic template Messenger Platform.
+const (
+	maxCardTitle    = 80
+	maxCardSubtitle = 80
+)
+
+// buildRichDM menyusun DM terstruktur (template/quick reply/media) sebagai
+// satu pesan: private reply hanya boleh satu pesan, pesan lanjutan ke IG user
+// id ditolak Meta sebelum user membalas. Gambar + teks dikirim sebagai kartu
+// (generic template); kalau teks tidak muat di kartu, atau medianya video,
+// teks dan tombol didahulukan dan media tidak ikut.
+// Nil berarti cukup kirim teks biasa.
+func buildRichDM(rd types.IGReplyData) *ig.Message {
+	var buttons []ig.Button
+	for _, btn := range rd.Buttons {
+		if btn.Enabled && btn.URL != "" {
+			buttons = append(buttons, ig.Button{Type: "web_url", Title: btn.Title, URL: btn.URL})
+		}
+	}
+	var qrs []ig.QuickReply
+	for _, qr := range rd.QuickReplies {
+		if qr.Title == "" {
+			continue
+		}
+		payload := qr.Payload
+		if payload == "" {
+			payload = qr.Title
+		}
+		qrs = append(qrs, ig.QuickReply{ContentType: "text", Title: qr.Title, Payload: payload})
+	}
+	hasMedia := rd.Media != nil && rd.Media.URL != "" && (rd.Media.Type == "image" || rd.Media.Type == "video")
+	title, subtitle, fitsCard := splitCardText(rd.DMMessage)
+
+	var msg ig.Message
+	switch {
+	case hasMedia && rd.Media.Type == "image" && (rd.DMMessage != "" || len(buttons) > 0) && fitsCard:
+		// kartu: gambar + judul (+ subjudul) + tombol
+		msg = ig.GenericTemplate(ig.GenericElement{Title: title, Subtitle: subtitle, ImageURL: rd.Media.URL, Buttons: buttons})
+	case len(buttons) > 0:
+		msg = ig.ButtonTemplate(rd.DMMessage, buttons)
+	case hasMedia && rd.DMMessage == "":
+		msg = ig.MediaMessage(rd.Media.Type, rd.Media.URL)
+	case len(qrs) > 0:
+		msg = ig.TextMessage(rd.DMMessage)
+	default:
+		return nil
+	}
+	if len(qrs) > 0 {
+		msg = msg.WithQuickReplies(qrs)
+	}
+	return &msg
+}
+
+// splitCardText membagi teks DM ke judul dan subjudul kartu (dipotong di
+// spasi kalau bisa). ok=false kalau teks tidak muat di keduanya.
+func splitCardText(text string) (title, subtitle string, ok bool) {
+	r := []rune(text)
+	if len(r) <= maxCardTitle {
+		return text, "", true
+	}
+	if len(r) > maxCardTitle+maxCardSubtitle {
+		return "", "", false
+	}
+	for i := maxCardTitle; i > 0; i-- {
+		if r[i] == ' ' && len(r)-i-1 <= maxCardSubtitle {
+			return string(r[:i]), string(r[i+1:]), true
+		}
+	}
+	return string(r[:maxCardTitle]), string(r[maxCardTitle:]), true
+}
+
+// commentTrigger mencari trigger node IG_COMMENT_RECEIVED beserta igUserCommentData.
+func commentTrigger(wf *types.WorkflowDefinition) (types.Node, types.IGUserCommentData, bool) {
+	var cfg types.IGUserCommentData
+	for _, n := range wf.Nodes {
+		// n.Data["type"] di JSON menyimpan trigger type
+		if t, _ := n.Data["type"].(string); t == string(types.TriggerIGCommentReceived) {
+			b, _ := json.Marshal(n.Data["igUserCommentData"])
+			_ = json.Unmarshal(b, &cfg)
+			return n, cfg, true
+		}
+	}
+	return types.Node{}, cfg, false
+}
+
+// postSelected: SelectedPostID boleh berisi media id, shortcode atau permalink.
+func (p *CommentProcessor) postSelected(ctx context.Context, ev CommentEvent, refs []string) bool {
+	if contains(refs, ev.PostID) {
+		return true
+	}
+	if p.Media == nil {
+		return false
+	}
+	ids, errs := p.Media.ResolveAll(ctx, ev.IGBusinessID, refs)
+	for ref, err := range errs {
+		log.Printf("[WARN] resolve post ref=%q acct=%s: %v", ref, ev.IGBusinessID, err)
+	}
+	return contains(ids, ev.PostID)
+}
+
+// replyScopeMatches mengecek posisi komentar di thread terhadap ReplyScope trigger.
+func (p *CommentProcessor) replyScopeMatches(ctx context.Context, ev CommentEvent, scope string, parent func() *ig.Comment) bool {
+	isReply := ev.ParentCommentID != ""
+	switch scope {
+	case "", types.ReplyScopeAll:
+		return true
+	case types.ReplyScopeTopLevel:
+		return !isReply
+	case types.ReplyScopeReplies:
+		return isReply
+	case types.ReplyScopeRepliesToBrand:
+		if !isReply {
+			return false
+		}
+		own, err := p.kv.Exists(ctx, OwnCommentKey(ev.IGBusinessID, ev.ParentCommentID))
+		if err != nil {
+			log.Printf("[WARN] own comment marker: %v", err)
+		}
+		if own {
+			return true
+		}
+		pc := parent()
+		return pc != nil && pc.From.ID == ev.IGBusinessID
+	default:
+		log.Printf("[WARN] unknown reply scope=%q", scope)
+		return false
+	}
+}
+
+// parentComment: nil untuk komentar top-level atau kalau lookup gagal.
+func (p *CommentProcessor) parentComment(ctx context.Context, ev CommentEvent) *ig.Comment {
+	if ev.ParentCommentID == "" || p.Comments == nil {
+		return nil
+	}
+	c, err := p.Comments.Comment(ctx, ev.IGBusinessID, ev.ParentCommentID)
+	if err != nil {
+		log.Printf("[WARN] parent comment=%s acct=%s: %v", ev.ParentCommentID, ev.IGBusinessID, err)
+		return nil
+	}
+	return c
+}
+
+// postTargeted mengevaluasi mode targeting trigger terhadap post event.
+func (p *CommentProcessor) postTargeted(ctx context.Context, wfID string, ev CommentEvent, cfg types.IGUserCommentData) bool {
+	t := cfg.Targeting
+	if ev.Reconciled && t.HasAdFilter() {
+		// tanpa ad id komentar iklan akan terbaca organik
+		return false
+	}
+	if !t.MatchesAd(ev.AdID) {
+		return false
+	}
+	mode := t.EffectiveMode()
+	switch mode {
+	case types.PostTargetSelected:
+		if !p.postSelected(ctx, ev, cfg.SelectedPostID) {
+			return false
+		}
+	case types.PostTargetAll, types.PostTargetCaption, types.PostTargetNextPost:
+	default:
+		log.Printf("[WARN] unknown post targeting mode=%q wf=%s", t.Mode, wfID)
+		return false
+	}
+	if !t.NeedsMedia() {
+		return true
+	}
+	if p.Media == nil {
+		log.Printf("[WARN] post targeting mode=%s needs media catalog wf=%s", mode, wfID)
+		return false
+	}
+	m, err := p.Media.Media(ctx, ev.IGBusinessID, ev.PostID)
+	if err != nil {
+		log.Printf("[WARN] media metadata post=%s acct=%s: %v", ev.PostID, ev.IGBusinessID, err)
+		return false
+	}
+	if !t.MatchesMedia(m.Caption, m.MediaProductType, m.Timestamp) {
+		return false
+	}
+	if mode == types.PostTargetNextPost {
+		return p.isNextPost(ctx, wfID, ev.IGBusinessID, t, m)
+	}
+	return true
+}
+
+// TTL state next_post di Redis (anchor & post terpilih)
+const nextPostStateTTL = 365 * 24 * time.Hour
+
+// isNextPost: post event adalah post pertama yang terbit setelah anchor.
+// Post terpilih dikunci di Redis supaya tidak bergeser setelah post berikutnya
+// terbit; key-nya memuat anchor, jadi mengubah ArmedAt memilih ulang post.
+func (p *CommentProcessor) isNextPost(ctx context.Context, wfID, acct string, t types.PostTargeting, m *repo.MediaRow) bool {
+	anchor, ok := p.nextPostAnchor(ctx, wfID, t)
+	if !ok {
+		return false
+	}
+	chosenKey := nextPostMediaKey(wfID, anchor)
+	if id, err := p.kv.Get(ctx, chosenKey); err == nil && id != "" {
+		return id == m.ID
+	}
+	if m.Timestamp == nil || !m.Timestamp.After(anchor) {
+		return false
+	}
+
+	// post lain yang terbit lebih dulu setelah anchor menang
+	first, err := p.Media.FirstMediaAfter(ctx, acct, anchor)
+	if err != nil {
+		log.Printf("[WARN] next_post first media acct=%s: %v", acct, err)
+		return false
+	}
+	if first != nil && first.ID != m.ID {
+		return false
+	}
+	ok, err = p.kv.SetNX(ctx, chosenKey, m.ID, nextPostStateTTL)
+	if err != nil {
+		return false
+	}
+	if !ok {
+		// sudah dipilih oleh event lain
+		id, _ := p.kv.Get(ctx, chosenKey)
+		return id == m.ID
+	}
+	log.Printf("[TARGET] next_post wf=%s media=%s", wfID, m.ID)
+	return true
+}
+
+// nextPostAnchor: ArmedAt dari editor, atau waktu event pertama yang dievaluasi.
+func (p *CommentProcessor) nextPostAnchor(ctx context.Context, wfID string, t types.PostTargeting) (time.Time, bool) {
+	if t.ArmedAt != nil {
+		return t.ArmedAt.UTC(), true
+	}
+	armedKey := "wf:next_post:" + wfID + ":armed"
+	if _, err := p.kv.SetNX(ctx, armedKey, time.Now().UTC().Format(time.RFC3339), nextPostStateTTL); err != nil {
+		log.Printf("[WARN] next_post anchor wf=%s: %v", wfID, err)
+		return time.Time{}, false
+	}
+	v, err := p.kv.Get(ctx, armedKey)
+	if err != nil {
+		log.Printf("[WARN] next_post anchor wf=%s: %v", wfID, err)
+		return time.Time{}, false
+	}
+	anchor, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return anchor, true
+}
+
+func nextPostMediaKey(wfID string, anchor time.Time) string {
+	return "wf:next_post:" + wfID + ":" + strconv.FormatInt(anchor.Unix(), 10) + ":media"
+}
+
+// TargetedPostIDs: referensi post (id/shortcode/permalink) yang dipantau
+// trigger komentar workflow mode selected tanpa filter iklan (untuk
+// rekonsiliasi), tanpa duplikat.
+func TargetedPostIDs(wfs []*types.WorkflowDefinition) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, wf := range wfs {
+		_, cfg, ok := commentTrigger(wf)
+		if !ok || cfg.Targeting.EffectiveMode() != types.PostTargetSelected || cfg.Targeting.HasAdFilter() {
+			continue
+		}
+		for _, id := range cfg.SelectedPostID {
+			if id != "" && !seen[id] {
+				seen[id] = true
+				out = append(out, id)
+			}
+		}
+	}
+	return out
+}
+
+// CatalogTargetedPostIDs: media yang dipantau workflow mode all/caption/next_post
+// (tidak punya daftar post), dipilih dari media terbaru katalog. Workflow
+// dengan filter iklan dilewati karena komentar hasil rekonsiliasi tidak
+// membawa ad id.
+func (p *CommentProcessor) CatalogTargetedPostIDs(ctx context.Context, acct string, wfs []*types.WorkflowDefinition, recent []repo.MediaRow) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	for _, wf := range wfs {
+		_, cfg, ok := commentTrigger(wf)
+		if !ok || cfg.Targeting.HasAdFilter() {
+			continue
+		}
+		t := cfg.Targeting
+		switch t.EffectiveMode() {
+		case types.PostTargetAll, types.PostTargetCaption:
+			for _, m := range recent {
+				if t.MatchesMedia(m.Caption, m.MediaProductType, m.Timestamp) {
+					add(m.ID)
+				}
+			}
+		case types.PostTargetNextPost:
+			add(p.nextPostChosen(ctx, wf.ID, acct, t))
+		}
+	}
+	return out
+}
+
+// nextPostChosen: post terpilih next_post tanpa mengubah state (belum di-arm = "").
+func (p *CommentProcessor) nextPostChosen(ctx context.Context, wfID, acct string, t types.PostTargeting) string {
+	var anchor time.Time
+	if t.ArmedAt != nil {
+		anchor = t.ArmedAt.UTC()
+	} else {
+		v, err := p.kv.Get(ctx, "wf:next_post:"+wfID+":armed")
+		if err != nil {
+			return ""
+		}
+		if anchor, err = time.Parse(time.RFC3339, v); err != nil {
+			return ""
+		}
+	}
+	if id, err := p.kv.Get(ctx, nextPostMediaKey(wfID, anchor)); err == nil && id != "" {
+		return id
+	}
+	if p.Media == nil {
+		return ""
+	}
+	first, err := p.Media.FirstMediaAfter(ctx, acct, anchor)
+	if err != nil || first == nil || !t.MatchesMedia(first.Caption, first.MediaProductType, first.Timestamp) {
+		return ""
+	}
+	return first.ID
+}
+
+// BrandIDForAccount memetakan IG business account ke brand/tenant.
+func BrandIDForAccount(igBusinessID string) string {
+	// TODO: lookup DB mapping pageID -> brand/tenant
+	return "brand-" + igBusinessID
+}
+
 func contains(a []string, x string) bool {
 	for _, v := range a {
 		if v == x {
@@ -0,0 +1,42 @@
+package processor
+
+import (
+	"context"
+	"ig-webhook/internal/store"
+	"ig-webhook/internal/types"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type staticWorkflows []*types.WorkflowDefinition
+
+func (s staticWorkflows) ListActiveWorkflowsForIGAccount(string) ([]*types.WorkflowDefinition, error) {
+	return s, nil
+}
+
+func TestProcessDisabledActionsDoNotClaimExecution(t *testing.T) {
+	mr := miniredis.RunT(t)
+	wf := &types.WorkflowDefinition{
+		ID: "w1",
+		Nodes: []types.Node{
+			{ID: "t1", Data: map[string]interface{}{"type": string(types.TriggerIGCommentReceived), "igUserCommentData": map[string]interface{}{"selectedPostId": []string{"m1"}}}},
+			{ID: "a1", Data: map[string]interface{}{"type": string(types.ActionIGSendMsg), "igReplyData": map[string]interface{}{
+				"dmMessage":    "hi",
+				"safetyConfig": map[string]interface{}{"actionTypes": map[string]bool{"enableCommentReply": false, "enableDMReply": false}},
+			}}},
+		},
+		Edges: []types.Edge{{Source: "t1", Target: "a1"}},
+	}
+	p := NewCommentProcessor(store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})), nil, staticWorkflows{wf})
+
+	err := p.Process(context.Background(), CommentEvent{EventID: "e1", IGBusinessID: "acct", CommentID: "c1", PostID: "m1", FromIGUserID: "u1", Text: "halo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// aksi nonaktif: komentar masih bisa diproses setelah aksi diaktifkan
+	if mr.Exists("idem:exec:w1:a1:c1") {
+		t.Fatal("disabled action claimed idem:exec")
+	}
+}
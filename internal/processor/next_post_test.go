@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"context"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/store"
+	"ig-webhook/internal/types"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type fakeCatalog struct {
+	media []repo.MediaRow // urut terbit
+}
+
+func (f *fakeCatalog) ResolveAll(context.Context, string, []string) ([]string, map[string]error) {
+	return nil, nil
+}
+
+func (f *fakeCatalog) Media(_ context.Context, _, id string) (*repo.MediaRow, error) {
+	for i := range f.media {
+		if f.media[i].ID == id {
+			return &f.media[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeCatalog) FirstMediaAfter(_ context.Context, _ string, t time.Time) (*repo.MediaRow, error) {
+	for i := range f.media {
+		if f.media[i].Timestamp.After(t) {
+			return &f.media[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func TestIsNextPostRearmsOnAnchorChange(t *testing.T) {
+	mr := miniredis.RunT(t)
+	p := NewCommentProcessor(store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})), nil, nil)
+	at := func(day int) *time.Time {
+		v := time.Date(2026, 10, day, 12, 0, 0, 0, time.UTC)
+		return &v
+	}
+	cat := &fakeCatalog{media: []repo.MediaRow{{ID: "m1", Timestamp: at(2)}, {ID: "m2", Timestamp: at(5)}}}
+	p.Media = cat
+	ctx := context.Background()
+
+	first := types.PostTargeting{Mode: types.PostTargetNextPost, ArmedAt: at(1)}
+	if !p.isNextPost(ctx, "wf1", "acct", first, &cat.media[0]) {
+		t.Fatal("m1 is the first post after the anchor")
+	}
+	if p.isNextPost(ctx, "wf1", "acct", first, &cat.media[1]) {
+		t.Fatal("m2 must not replace the chosen post")
+	}
+
+	// editor meng-arm ulang: post terpilih lama tidak boleh terbawa
+	rearmed := types.PostTargeting{Mode: types.PostTargetNextPost, ArmedAt: at(3)}
+	if p.isNextPost(ctx, "wf1", "acct", rearmed, &cat.media[0]) {
+		t.Fatal("m1 was published before the new anchor")
+	}
+	if !p.isNextPost(ctx, "wf1", "acct", rearmed, &cat.media[1]) {
+		t.Fatal("m2 is the first post after the new anchor")
+	}
+}
+
+func commentWorkflow(id string, cfg types.IGUserCommentData) *types.WorkflowDefinition {
+	return &types.WorkflowDefinition{ID: id, Nodes: []types.Node{{Data: map[string]interface{}{
+		"type":              string(types.TriggerIGCommentReceived),
+		"igUserCommentData": cfg,
+	}}}}
+}
+
+func TestCatalogTargetedPostIDs(t *testing.T) {
+	mr := miniredis.RunT(t)
+	p := NewCommentProcessor(store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})), nil, nil)
+	at := func(day int) *time.Time {
+		v := time.Date(2026, 10, day, 12, 0, 0, 0, time.UTC)
+		return &v
+	}
+	cat := &fakeCatalog{media: []repo.MediaRow{
+		{ID: "m1", Caption: "halo", MediaProductType: "FEED", Timestamp: at(2)},
+		{ID: "m2", Caption: "ikut #giveaway", MediaProductType: "REELS", Timestamp: at(5)},
+	}}
+	p.Media = cat
+	recent := []repo.MediaRow{cat.media[1], cat.media[0]}
+
+	cases := []struct {
+		name string
+		cfg  types.IGUserCommentData
+		want []string
+	}{
+		{"selected skipped", types.IGUserCommentData{SelectedPostID: []string{"m1"}}, nil},
+		{"all", types.IGUserCommentData{Targeting: types.PostTargeting{Mode: types.PostTargetAll}}, []string{"m2", "m1"}},
+		{"all reels only", types.IGUserCommentData{Targeting: types.PostTargeting{Mode: types.PostTargetAll, ReelsOnly: true}}, []string{"m2"}},
+		{"caption", types.IGUserCommentData{Targeting: types.PostTargeting{Mode: types.PostTargetCaption, CaptionKeywords: []string{"#giveaway"}}}, []string{"m2"}},
+		{"next post", types.IGUserCommentData{Targeting: types.PostTargeting{Mode: types.PostTargetNextPost, ArmedAt: at(3)}}, []string{"m2"}},
+		{"next post not armed", types.IGUserCommentData{Targeting: types.PostTargeting{Mode: types.PostTargetNextPost}}, nil},
+		// komentar hasil rekonsiliasi tidak membawa ad id
+		{"organic only skipped", types.IGUserCommentData{Targeting: types.PostTargeting{Mode: types.PostTargetAll, AdScope: types.AdScopeOrganic}}, nil},
+		{"exclude ads skipped", types.IGUserCommentData{Targeting: types.PostTargeting{Mode: types.PostTargetAll, ExcludeAdIDs: []string{"ad1"}}}, nil},
+	}
+	for _, c := range cases {
+		got := p.CatalogTargetedPostIDs(context.Background(), "acct", []*types.WorkflowDefinition{commentWorkflow("wf-"+c.name, c.cfg)}, recent)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+	if mr.Exists("wf:next_post:wf-next post not armed:armed") {
+		t.Fatal("reconciler must not arm next_post workflows")
+	}
+
+	selected := []*types.WorkflowDefinition{
+		commentWorkflow("w1", types.IGUserCommentData{SelectedPostID: []string{"m1"}}),
+		commentWorkflow("w2", types.IGUserCommentData{SelectedPostID: []string{"m2"}, Targeting: types.PostTargeting{AdIDs: []string{"ad1"}}}),
+	}
+	if got := TargetedPostIDs(selected); !reflect.DeepEqual(got, []string{"m1"}) {
+		t.Fatalf("selected with ad filter: got %v, want [m1]", got)
+	}
+}
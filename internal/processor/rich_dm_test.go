@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/types"
+	"strings"
+	"testing"
+)
+
+// richKind meringkas pesan jadi "text" | "button" | "generic" | "image" | "video", "+qr" kalau ada quick replies.
+func richKind(m ig.Message) string {
+	k := "text"
+	if m.Attachment != nil {
+		k = m.Attachment.Type
+		if k == "template" {
+			k = m.Attachment.Payload.TemplateType
+		}
+	}
+	if len(m.QuickReplies) > 0 {
+		k += "+qr"
+	}
+	return k
+}
+
+func TestBuildRichDM(t *testing.T) {
+	short := "Cek katalog kami"
+	long := strings.Repeat("a", 81)
+	words := strings.Repeat("promo ", 20) + "hari ini"
+	tooLong := strings.Repeat("a", 161)
+	btn := []types.ReplyButton{{Title: "Beli", URL: "https://x/beli", Enabled: true}}
+	qr := []types.ReplyQuickReply{{Title: "Info"}}
+	image := &types.ReplyMedia{Type: "image", URL: "https://x/a.jpg"}
+	video := &types.ReplyMedia{Type: "video", URL: "https://x/a.mp4"}
+
+	cases := []struct {
+		name string
+		rd   types.IGReplyData
+		want string
+	}{
+		{"text only", types.IGReplyData{DMMessage: short}, ""},
+		{"quick replies", types.IGReplyData{DMMessage: short, QuickReplies: qr}, "text+qr"},
+		{"buttons", types.IGReplyData{DMMessage: short, Buttons: btn}, "button"},
+		{"media only", types.IGReplyData{Media: image}, "image"},
+		{"text + image", types.IGReplyData{DMMessage: short, Media: image}, "generic"},
+		{"text + video + qr", types.IGReplyData{DMMessage: short, Media: video, QuickReplies: qr}, "text+qr"},
+		{"image + buttons short text", types.IGReplyData{DMMessage: short, Media: image, Buttons: btn}, "generic"},
+		{"image + buttons long text", types.IGReplyData{DMMessage: long, Media: image, Buttons: btn}, "generic"},
+		{"image + words", types.IGReplyData{DMMessage: words, Media: image, QuickReplies: qr}, "generic+qr"},
+		{"image + text too long for card", types.IGReplyData{DMMessage: tooLong, Media: image, Buttons: btn}, "button"},
+		{"video + buttons", types.IGReplyData{DMMessage: short, Media: video, Buttons: btn, QuickReplies: qr}, "button+qr"},
+		{"disabled button ignored", types.IGReplyData{DMMessage: short, Buttons: []types.ReplyButton{{Title: "x", URL: "https://x"}}}, ""},
+	}
+	for _, c := range cases {
+		msg := buildRichDM(c.rd)
+		got := ""
+		if msg != nil {
+			got = richKind(*msg)
+		}
+		if got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+			continue
+		}
+		// teks DM tidak boleh hilang
+		if c.rd.DMMessage != "" && msg != nil && !richHasText(*msg, c.rd.DMMessage) {
+			t.Errorf("%s: dm text dropped", c.name)
+		}
+	}
+}
+
+func richHasText(m ig.Message, text string) bool {
+	if m.Text == text {
+		return true
+	}
+	a := m.Attachment
+	if a == nil {
+		return false
+	}
+	if a.Payload.Text == text {
+		return true
+	}
+	if len(a.Payload.Elements) == 0 {
+		return false
+	}
+	el := a.Payload.Elements[0]
+	if len([]rune(el.Title)) > maxCardTitle || len([]rune(el.Subtitle)) > maxCardSubtitle {
+		return false
+	}
+	return el.Title+el.Subtitle == text || el.Title+" "+el.Subtitle == text
+}
@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"ig-webhook/internal/ig"
+	"regexp"
+)
+
+var placeholderRe = regexp.MustCompile(`\{\{\s*([a-z_.]+)\s*\}\}`)
+
+// templateVars: konteks eksekusi komentar yang bisa dipakai di pesan, mis.
+// "Hai @{{username}}" atau "{{parent.username}}". Parent kosong untuk komentar top-level.
+func templateVars(ev CommentEvent, parent *ig.Comment) map[string]string {
+	vars := map[string]string{
+		"username":  ev.FromUsername,
+		"comment":   ev.Text,
+		"parent.id": ev.ParentCommentID,
+		// kosong kalau top-level / parent tidak bisa diambil
+		"parent.username": "",
+		"parent.text":     "",
+	}
+	if parent != nil {
+		vars["parent.username"] = parent.Username
+		if vars["parent.username"] == "" {
+			vars["parent.username"] = parent.From.Username
+		}
+		vars["parent.text"] = parent.Text
+	}
+	return vars
+}
+
+// renderTemplate mengganti {{nama}} dengan nilai vars. Placeholder yang
+// tidak dikenal dibiarkan apa adanya.
+func renderTemplate(s string, vars map[string]string) string {
+	return placeholderRe.ReplaceAllStringFunc(s, func(m string) string {
+		key := placeholderRe.FindStringSubmatch(m)[1]
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+func usesParent(ss ...string) bool {
+	for _, s := range ss {
+		for _, m := range placeholderRe.FindAllStringSubmatch(s, -1) {
+			if m[1] == "parent.username" || m[1] == "parent.text" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package processor
+
+import (
+	"ig-webhook/internal/ig"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	ev := CommentEvent{FromUsername: "budi", Text: "harga?", ParentCommentID: "c1"}
+	parent := &ig.Comment{ID: "c1", Text: "Promo hari ini", From: ig.CommentAuthor{Username: "tokoku"}}
+
+	got := renderTemplate("Hai @{{username}}, balasan untuk {{ parent.username }}: {{parent.text}} {{unknown}}", templateVars(ev, parent))
+	want := "Hai @budi, balasan untuk tokoku: Promo hari ini {{unknown}}"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	// komentar top-level: placeholder parent kosong
+	if got := renderTemplate("[{{parent.text}}]", templateVars(CommentEvent{}, nil)); got != "[]" {
+		t.Fatalf("top-level render = %q", got)
+	}
+	if got := renderTemplate("[{{parent.id}}]", templateVars(CommentEvent{}, nil)); got != "[]" {
+		t.Fatalf("top-level parent.id = %q", got)
+	}
+}
+
+func TestUsesParent(t *testing.T) {
+	if usesParent("Hai {{username}}", "") {
+		t.Fatal("no parent placeholder expected")
+	}
+	if !usesParent("", "re: {{ parent.text }}") {
+		t.Fatal("parent placeholder not detected")
+	}
+}
@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hibiken/asynq"
+)
+
+// accountRef: field yang dipakai semua payload task untuk akun IG.
+type accountRef struct {
+	IGBusinessID string
+}
+
+// Hanya task aksi yang dibatalkan; task purge & event status harus tetap jalan
+// (deauthorize dan data deletion biasanya datang bersamaan).
+var cancellableTypes = map[string]bool{
+	TypeSendDM:          true,
+	TypeSendPublicReply: true,
+}
+
+// CancelAccountTasks menghapus task aksi (DM/public reply) pending/scheduled/retry
+// milik akun IG dari semua queue. Task yang sedang berjalan dibiarkan (handler
+// akan melihat status integrasi).
+func CancelAccountTasks(insp *asynq.Inspector, igBusinessID string) (int, error) {
+	n := 0
+	for _, qname := range []string{QueueDefault, QueuePriority} {
+		for _, list := range []func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error){
+			insp.ListPendingTasks, insp.ListScheduledTasks, insp.ListRetryTasks,
+		} {
+			deleted, err := cancelFromList(insp, qname, list, igBusinessID)
+			n += deleted
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func cancelFromList(insp *asynq.Inspector, qname string, list func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error), igBusinessID string) (int, error) {
+	const pageSize = 500
+	n := 0
+	for page := 1; ; page++ {
+		tasks, err := list(qname, asynq.PageSize(pageSize), asynq.Page(page))
+		if err != nil {
+			if errors.Is(err, asynq.ErrQueueNotFound) {
+				return n, nil
+			}
+			return n, err
+		}
+		deletedInPage := 0
+		for _, ti := range tasks {
+			if !cancellable(ti, igBusinessID) {
+				continue
+			}
+			if err := insp.DeleteTask(qname, ti.ID); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+				return n, err
+			}
+			n++
+			deletedInPage++
+		}
+		if len(tasks) < pageSize {
+			return n, nil
+		}
+		// task yang dihapus menggeser halaman berikutnya
+		if deletedInPage > 0 {
+			page--
+		}
+	}
+}
+
+func cancellable(ti *asynq.TaskInfo, igBusinessID string) bool {
+	if !cancellableTypes[ti.Type] {
+		return false
+	}
+	var ref accountRef
+	return json.Unmarshal(ti.Payload, &ref) == nil && ref.IGBusinessID == igBusinessID
+}
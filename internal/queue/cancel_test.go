@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+)
+
+func TestCancelAccountTasksKeepsPurgeAndStatusEvents(t *testing.T) {
+	mr := miniredis.RunT(t)
+	opt := asynq.RedisClientOpt{Addr: mr.Addr()}
+	client := asynq.NewClient(opt)
+	defer client.Close()
+	insp := asynq.NewInspector(opt)
+	defer insp.Close()
+
+	enqueue := func(task *asynq.Task, opts []asynq.Option) {
+		t.Helper()
+		if _, err := client.Enqueue(task, opts...); err != nil {
+			t.Fatalf("enqueue %s: %v", task.Type(), err)
+		}
+	}
+	enqueue(NewDMTask(TaskSendDMPayload{IGBusinessID: "acct1", CommentID: "c1", WorkflowID: "w", NodeID: "n"}, 0))
+	enqueue(NewPublicReplyTask(TaskSendPublicReplyPayload{IGBusinessID: "acct1", CommentID: "c1"}, time.Hour))
+	enqueue(NewDMTask(TaskSendDMPayload{IGBusinessID: "acct2", CommentID: "c2", WorkflowID: "w", NodeID: "n"}, 0))
+	enqueue(NewPurgeAccountDataTask(PurgeAccountDataPayload{IGBusinessID: "acct1", ConfirmationCode: "code1"}))
+	enqueue(NewIntegrationStatusChangedTask(IntegrationStatusChangedPayload{IGBusinessID: "acct1", Status: "revoked", At: time.Now()}))
+
+	n, err := CancelAccountTasks(insp, "acct1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 cancelled tasks, got %d", n)
+	}
+
+	remaining := map[string]int{}
+	for _, q := range []string{QueueDefault, QueuePriority} {
+		for _, list := range []func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error){insp.ListPendingTasks, insp.ListScheduledTasks} {
+			tasks, err := list(q)
+			if err != nil {
+				continue
+			}
+			for _, ti := range tasks {
+				remaining[ti.Type]++
+			}
+		}
+	}
+	if remaining[TypePurgeAccountData] != 1 || remaining[TypeIntegrationStatusChanged] != 1 {
+		t.Fatalf("purge/status tasks cancelled: %v", remaining)
+	}
+	if remaining[TypeSendDM] != 1 || remaining[TypeSendPublicReply] != 0 {
+		t.Fatalf("unexpected action tasks left: %v", remaining)
+	}
+}
@@ -0,0 +1,43 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// DeferError menandai task yang harus dijalankan ulang pada waktu tertentu
+// (mis. kuota rate limit habis). Tidak dihitung sebagai retry.
+type DeferError struct {
+	Until  time.Time
+	Reason string
+}
+
+func (e *DeferError) Error() string {
+	return fmt.Sprintf("deferred until %s: %s", e.Until.UTC().Format(time.RFC3339), e.Reason)
+}
+
+func Defer(until time.Time, reason string) error {
+	return &DeferError{Until: until, Reason: reason}
+}
+
+// RetryDelay dipasang sebagai asynq.Config.RetryDelayFunc.
+func RetryDelay(n int, err error, t *asynq.Task) time.Duration {
+	var de *DeferError
+	if errors.As(err, &de) {
+		if d := time.Until(de.Until); d > 0 {
+			return d
+		}
+		return time.Second
+	}
+	return asynq.DefaultRetryDelayFunc(n, err, t)
+}
+
+// IsFailure dipasang sebagai asynq.Config.IsFailure; task yang di-defer
+// tidak menambah retry counter.
+func IsFailure(err error) bool {
+	var de *DeferError
+	return !errors.As(err, &de)
+}
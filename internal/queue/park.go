@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ig-webhook/internal/store"
+	"sort"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Task yang tidak bisa jalan karena integrasi bermasalah (token tidak ada,
+// kadaluarsa, integrasi non-aktif) di-park per akun IG, lalu di-enqueue
+// ulang setelah integrasi diperbaiki.
+const parkedTTL = 14 * 24 * time.Hour
+
+type ParkedTask struct {
+	Key      string          `json:"key"` // <type>:<task key>[:<asynq task id>]
+	Type     string          `json:"type"`
+	Payload  json.RawMessage `json:"payload"`
+	Reason   string          `json:"reason"`
+	ParkedAt time.Time       `json:"parkedAt"`
+}
+
+type Parker struct {
+	kv *store.RedisStore
+	q  *asynq.Client
+}
+
+func NewParker(kv *store.RedisStore, q *asynq.Client) *Parker {
+	return &Parker{kv: kv, q: q}
+}
+
+func parkedKey(igBusinessID string) string { return "parked:" + igBusinessID }
+
+// Park menyimpan task; task yang sama hanya disimpan sekali.
+func (p *Parker) Park(ctx context.Context, igBusinessID, taskKey string, t *asynq.Task, reason string) error {
+	key := t.Type() + ":" + taskKey
+	if w := t.ResultWriter(); w != nil {
+		// taskKey DM (wf:node:user) tidak unik per komentar
+		key += ":" + w.TaskID()
+	}
+	pt := ParkedTask{
+		Key:      key,
+		Type:     t.Type(),
+		Payload:  t.Payload(),
+		Reason:   reason,
+		ParkedAt: time.Now().UTC(),
+	}
+	b, _ := json.Marshal(pt)
+	return p.kv.HSet(ctx, parkedKey(igBusinessID), pt.Key, string(b), parkedTTL)
+}
+
+// List task yang di-park untuk akun, terlama dulu.
+func (p *Parker) List(ctx context.Context, igBusinessID string) ([]ParkedTask, error) {
+	m, err := p.kv.HGetAll(ctx, parkedKey(igBusinessID))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ParkedTask, 0, len(m))
+	for _, raw := range m {
+		var pt ParkedTask
+		if json.Unmarshal([]byte(raw), &pt) == nil {
+			out = append(out, pt)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ParkedAt.Before(out[j].ParkedAt) })
+	return out, nil
+}
+
+// Retry meng-enqueue ulang semua task yang di-park untuk akun. Task yang
+// berhasil di-enqueue dihapus dari daftar park.
+func (p *Parker) Retry(ctx context.Context, igBusinessID string) (int, error) {
+	tasks, err := p.List(ctx, igBusinessID)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, pt := range tasks {
+		t, opts, err := requeueTask(pt)
+		if err != nil {
+			return n, fmt.Errorf("parked %s: %w", pt.Key, err)
+		}
+		if _, err := p.q.EnqueueContext(ctx, t, opts...); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+			return n, fmt.Errorf("enqueue parked %s: %w", pt.Key, err)
+		}
+		if err := p.kv.HDel(ctx, parkedKey(igBusinessID), pt.Key); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// requeueTask menyusun ulang task dengan opsi yang sama seperti saat dibuat.
+func requeueTask(pt ParkedTask) (*asynq.Task, []asynq.Option, error) {
+	switch pt.Type {
+	case TypeSendPublicReply:
+		var p TaskSendPublicReplyPayload
+		if err := json.Unmarshal(pt.Payload, &p); err != nil {
+			return nil, nil, err
+		}
+		t, opts := NewPublicReplyTask(p, 0)
+		return t, opts, nil
+	case TypeSendDM:
+		var p TaskSendDMPayload
+		if err := json.Unmarshal(pt.Payload, &p); err != nil {
+			return nil, nil, err
+		}
+		t, opts := NewDMTask(p, 0)
+		return t, opts, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported task type %q", pt.Type)
+	}
+}
+
+// Clear menghapus semua task yang di-park untuk akun (integrasi dicabut).
+func (p *Parker) Clear(ctx context.Context, igBusinessID string) error {
+	return p.kv.Del(ctx, parkedKey(igBusinessID))
+}
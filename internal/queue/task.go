@@ -1,7 +1,12 @@
 package queue
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/rate"
+	"log"
 	"math/rand"
 	"time"
 
@@ -14,24 +19,96 @@ const (
 
 	TypeSendPublicReply = "ig:send_public_reply"
 	TypeSendDM          = "ig:send_dm"
+
+	// Event: status integrasi berubah (mis. brand perlu connect ulang)
+	TypeIntegrationStatusChanged = "integration:status_changed"
+
+	// Hapus data akun IG (data deletion callback Meta)
+	TypePurgeAccountData = "integration:purge_data"
 )
 
 type TaskSendPublicReplyPayload struct {
-	BrandID    string
-	CommentID  string
-	Message    string
-	IGToken    string
+	BrandID      string
+	IGBusinessID string
+	CommentID    string
+	PostID       string
+	// Komentator; dipakai untuk cek cooldown kalau SuppressPublicReply
+	FromIGUserID string
+	Message      string
+	// Deprecated: token tidak lagi dibawa di payload; hanya untuk decode task
+	// lama yang masih antre. Token di-resolve saat eksekusi dari IGBusinessID.
+	IGToken    string `json:",omitempty"`
 	WorkflowID string
 	NodeID     string
+
+	// Limit efektif workflow (SafetyCombinedLimits setelah preset); 0 = tanpa limit.
+	MaxPerHour int
+	MaxPerDay  int
+	// SafetyDisabled = workflow mematikan safety (tanpa limit per workflow).
+	// Limit brand & akun IG tetap berlaku.
+	SafetyDisabled bool
+
+	// Waktu task dibuat (untuk batas staleness saat di-defer)
+	CreatedAt time.Time
+
+	// Timezone workflow (limit harian workflow ikut hari lokal) & quiet hours "HH:MM"
+	Timezone   string
+	QuietStart string
+	QuietEnd   string
+
+	// Kebijakan cooldown DM workflow (scope kosong = brand, 0 = 24 jam)
+	CooldownScope       string
+	CooldownMinutes     int
+	SuppressPublicReply bool
+
+	// DM berantai: di-enqueue oleh handler public reply setelah sukses,
+	// dengan delay CommentToDmDelay (detik) dihitung dari waktu reply terkirim.
+	NextDM           *TaskSendDMPayload
+	CommentToDmDelay [2]int
+	DMOnReplyFailure bool
 }
 
 type TaskSendDMPayload struct {
 	BrandID           string
+	IGBusinessID      string
 	RecipientIGUserID string
-	Message           string
-	IGToken           string
-	WorkflowID        string
-	NodeID            string
+	// CommentID terisi → DM dikirim sebagai Private Reply ke komentar ini
+	CommentID   string
+	CommentedAt time.Time
+	PostID      string
+	Message     string      // teks; juga fallback kalau Rich ditolak
+	Rich        *ig.Message // satu pesan: private reply tidak bisa disusul pesan lain
+	IGToken     string      `json:",omitempty"` // Deprecated: lihat TaskSendPublicReplyPayload.IGToken
+	WorkflowID  string
+	NodeID      string
+
+	MaxPerHour     int
+	MaxPerDay      int
+	SafetyDisabled bool
+	CreatedAt      time.Time
+
+	Timezone   string
+	QuietStart string
+	QuietEnd   string
+
+	CooldownScope   string
+	CooldownMinutes int
+}
+
+// cooldownPolicy mengubah field payload menjadi kebijakan cooldown.
+func cooldownPolicy(scope string, minutes int, suppress bool) rate.Cooldown {
+	if minutes <= 0 {
+		minutes = 24 * 60
+	}
+	return rate.Cooldown{Scope: scope, Duration: time.Duration(minutes) * time.Minute, SuppressPublicReply: suppress}
+}
+
+func (p TaskSendPublicReplyPayload) Cooldown() rate.Cooldown {
+	return cooldownPolicy(p.CooldownScope, p.CooldownMinutes, p.SuppressPublicReply)
+}
+
+func (p TaskSendDMPayload) Cooldown() rate.Cooldown {
+	return cooldownPolicy(p.CooldownScope, p.CooldownMinutes, false)
 }
 
 func RandDelaySec(min, max int) time.Duration {
@@ -61,5 +138,58 @@ func NewDMTask(p TaskSendDMPayload, delay time.Duration) (*asynq.Task, []asynq.O
 		asynq.ProcessIn(delay),
 		asynq.Timeout(15 * time.Second),
 	}
+	// ID deterministik supaya DM berantai tidak ter-enqueue dua kali
+	if p.CommentID != "" {
+		opts = append(opts, asynq.TaskID("dm:"+p.WorkflowID+":"+p.NodeID+":"+p.CommentID))
+	}
+	return t, opts
+}
+
+type IntegrationStatusChangedPayload struct {
+	IGBusinessID string
+	Status       string // repo.IntegrationStatus
+	Class        string // ig.ErrorClass penyebab (kosong kalau diubah operator)
+	Reason       string
+	At           time.Time
+}
+
+func NewIntegrationStatusChangedTask(p IntegrationStatusChangedPayload) (*asynq.Task, []asynq.Option) {
+	b, _ := json.Marshal(p)
+	t := asynq.NewTask(TypeIntegrationStatusChanged, b, asynq.Queue(QueuePriority))
+	opts := []asynq.Option{
+		asynq.MaxRetry(5),
+		asynq.Timeout(15 * time.Second),
+		// satu event per transisi
+		asynq.TaskID("integration_status:" + p.IGBusinessID + ":" + p.Status + ":" + p.At.UTC().Format(time.RFC3339)),
+	}
+	return t, opts
+}
+
+// EmitIntegrationStatusChanged meng-enqueue event transisi status integrasi
+// (dipakai worker, admin & onboarding). q nil = tidak ada event.
+func EmitIntegrationStatusChanged(ctx context.Context, q *asynq.Client, p IntegrationStatusChangedPayload) {
+	if q == nil {
+		return
+	}
+	t, opts := NewIntegrationStatusChangedTask(p)
+	if _, err := q.EnqueueContext(ctx, t, opts...); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		log.Printf("[ERR] enqueue integration status event acct=%s: %v", p.IGBusinessID, err)
+	}
+}
+
+type PurgeAccountDataPayload struct {
+	IGBusinessID     string
+	ConfirmationCode string
+	RequestedAt      time.Time
+}
+
+func NewPurgeAccountDataTask(p PurgeAccountDataPayload) (*asynq.Task, []asynq.Option) {
+	b, _ := json.Marshal(p)
+	t := asynq.NewTask(TypePurgeAccountData, b, asynq.Queue(QueueDefault))
+	opts := []asynq.Option{
+		asynq.MaxRetry(10),
+		asynq.Timeout(5 * time.Minute),
+		asynq.TaskID("purge:" + p.ConfirmationCode),
+	}
 	return t, opts
 }
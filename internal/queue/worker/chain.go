@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/rate"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// enqueueChainedDM meng-enqueue DM yang menempel di payload public reply.
+// Delay CommentToDmDelay dihitung dari sekarang (waktu reply sebenarnya).
+func enqueueChainedDM(ctx context.Context, d Deps, p queue.TaskSendPublicReplyPayload, reason string) error {
+	if p.NextDM == nil {
+		return nil
+	}
+	if d.Queue == nil {
+		log.Printf("[WARN] chained DM dropped, queue client not configured comment=%s", p.CommentID)
+		return nil
+	}
+	dm := *p.NextDM
+
+	q, _ := rate.ParseQuietHours(dm.QuietStart, dm.QuietEnd, rate.LoadLocation(dm.Timezone))
+	now := time.Now()
+	at := q.Next(now.Add(queue.RandDelaySec(p.CommentToDmDelay[0], p.CommentToDmDelay[1])), quietJitter)
+
+	t, opts := queue.NewDMTask(dm, at.Sub(now))
+	if _, err := d.Queue.EnqueueContext(ctx, t, opts...); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			return nil // sudah di-enqueue oleh percobaan sebelumnya
+		}
+		return err
+	}
+	log.Printf("[CHAIN] DM enqueued after public reply (%s) comment=%s at=%s", reason, p.CommentID, at.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// isLastAttempt: true kalau asynq tidak akan me-retry task ini lagi.
+func isLastAttempt(ctx context.Context) bool {
+	n, ok1 := asynq.GetRetryCount(ctx)
+	max, ok2 := asynq.GetMaxRetry(ctx)
+	return ok1 && ok2 && n >= max
+}
@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"encoding/json"
+	"ig-webhook/internal/queue"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+)
+
+const transientGraphError = `{"error":{"message":"Service temporarily unavailable","code":2,"is_transient":true}}`
+
+// withQueue memasang asynq client ke Deps dan mengembalikan inspector-nya.
+func withQueue(t *testing.T, d *Deps, mr *miniredis.Miniredis) *asynq.Inspector {
+	t.Helper()
+	opt := asynq.RedisClientOpt{Addr: mr.Addr()}
+	c := asynq.NewClient(opt)
+	t.Cleanup(func() { _ = c.Close() })
+	insp := asynq.NewInspector(opt)
+	t.Cleanup(func() { _ = insp.Close() })
+	d.Queue = c
+	return insp
+}
+
+// queuedDMs: task DM berantai yang menunggu di queue default.
+func queuedDMs(t *testing.T, insp *asynq.Inspector) int {
+	t.Helper()
+	pending, _ := insp.ListPendingTasks(queue.QueueDefault)
+	scheduled, _ := insp.ListScheduledTasks(queue.QueueDefault)
+	n := 0
+	for _, ti := range append(pending, scheduled...) {
+		if ti.Type == queue.TypeSendDM {
+			n++
+		}
+	}
+	return n
+}
+
+func replyPayload() queue.TaskSendPublicReplyPayload {
+	dm := dmPayload()
+	return queue.TaskSendPublicReplyPayload{
+		BrandID:      "b1",
+		IGBusinessID: "a1",
+		CommentID:    "c1",
+		PostID:       "p1",
+		FromIGUserID: "u1",
+		Message:      "thanks",
+		WorkflowID:   "w1",
+		NodeID:       "n0",
+		MaxPerHour:   10,
+		MaxPerDay:    100,
+		CreatedAt:    time.Now(),
+		NextDM:       &dm,
+	}
+}
+
+// failReplies: balasan komentar gagal dengan body, DM tetap sukses.
+func failReplies(status int, body string) func(string, map[string]json.RawMessage) (int, string) {
+	return func(path string, _ map[string]json.RawMessage) (int, string) {
+		if strings.HasSuffix(path, "/replies") {
+			return status, body
+		}
+		return 200, `{"id":"m1"}`
+	}
+}
+
+func TestPublicReplyChainsDMAfterSuccess(t *testing.T) {
+	d, mr, _ := newHandlerDeps(t)
+	insp := withQueue(t, &d, mr)
+
+	if err := runTask(t, d, queue.TypeSendPublicReply, replyPayload()); err != nil {
+		t.Fatalf("public reply: %v", err)
+	}
+	if n := queuedDMs(t, insp); n != 1 {
+		t.Fatalf("chained DMs %d, want 1", n)
+	}
+}
+
+func TestPublicReplyFailureSkipsDMWithoutPolicy(t *testing.T) {
+	d, mr, g := newHandlerDeps(t)
+	insp := withQueue(t, &d, mr)
+	// error permanen: gagal final di percobaan pertama
+	g.respond = failReplies(400, `{"error":{"message":"Invalid parameter","code":100,"error_subcode":33}}`)
+
+	if err := runTask(t, d, queue.TypeSendPublicReply, replyPayload()); err == nil {
+		t.Fatal("expected reply failure")
+	}
+	if n := queuedDMs(t, insp); n != 0 {
+		t.Fatalf("chained DMs %d after failed reply with DMOnReplyFailure off, want 0", n)
+	}
+}
+
+func TestPublicReplyRetryableFailureChainsDMOnLastAttempt(t *testing.T) {
+	d, mr, g := newHandlerDeps(t)
+	withQueue(t, &d, mr)
+	g.respond = failReplies(500, transientGraphError)
+	p := replyPayload()
+	p.DMOnReplyFailure = true
+
+	// bukan percobaan terakhir: asynq akan retry, DM belum dikirim
+	if err := runTask(t, d, queue.TypeSendPublicReply, p); err == nil {
+		t.Fatal("expected retryable reply failure")
+	}
+	if to := g.recipients(); len(to) != 0 {
+		t.Fatalf("DM sent before the last attempt: %v", to)
+	}
+
+	// percobaan terakhir (MaxRetry 0) lewat server asynq: DM berantai terkirim
+	srv := asynq.NewServer(asynq.RedisClientOpt{Addr: mr.Addr()}, asynq.Config{
+		Concurrency:     1,
+		Queues:          map[string]int{queue.QueueDefault: 1},
+		ShutdownTimeout: time.Second,
+		LogLevel:        asynq.FatalLevel,
+	})
+	mux := asynq.NewServeMux()
+	RegisterHandlers(mux, d)
+	if err := srv.Start(mux); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Shutdown)
+	b, _ := json.Marshal(p)
+	if _, err := d.Queue.Enqueue(asynq.NewTask(queue.TypeSendPublicReply, b, asynq.Queue(queue.QueueDefault)), asynq.MaxRetry(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if to := g.recipients(); len(to) > 0 {
+			if to[0].CommentID != "c1" {
+				t.Fatalf("chained DM sent to %+v, want private reply to c1", to[0])
+			}
+			return
+		}
+	}
+	t.Fatal("no DM after the reply failed on its last attempt")
+}
@@ -3,18 +3,20 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"ig-webhook/internal/ig"
 	"ig-webhook/internal/queue"
 	"ig-webhook/internal/rate"
-	"ig-webhook/internal/store"
+	"ig-webhook/internal/repo"
 	"log"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 )
 
-func registerDMHandler(mux *asynq.ServeMux, kv *store.RedisStore) {
-	lim := rate.NewLimiter(kv)
+func registerDMHandler(mux *asynq.ServeMux, d Deps) {
+	lim := rate.NewLimiter(d.KV)
 
 	mux.HandleFunc(queue.TypeSendDM, func(ctx context.Context, t *asynq.Task) error {
 		var p queue.TaskSendDMPayload
@@ -22,33 +24,153 @@ func registerDMHandler(mux *asynq.ServeMux, kv *store.RedisStore) {
 			return err
 		}
 
-		// Cooldown per user (contoh 24 jam)
-		cooling, _ := lim.IsCoolingDown(ctx, p.BrandID, p.RecipientIGUserID)
+		// Cooldown per user sesuai kebijakan workflow (scope brand/workflow/post).
+		// Error Redis → retry, jangan sampai user di-DM berulang.
+		cd := p.Cooldown()
+		cdKey := cd.Key(p.BrandID, p.WorkflowID, p.PostID, p.RecipientIGUserID)
+		cooling, err := lim.IsCoolingDown(ctx, cdKey)
+		if err != nil {
+			return err
+		}
 		if cooling {
 			log.Printf("[SKIP] DM cooldown brand=%s user=%s", p.BrandID, p.RecipientIGUserID)
 			return nil
 		}
 
-		// Rate limit (contoh angka default 25/jam, 200/hari)
-		allow, hc, dc, err := lim.CheckAndIncr(ctx, p.BrandID, "dm", 25, 200)
+		taskKey := p.WorkflowID + ":" + p.NodeID + ":" + p.RecipientIGUserID
+
+		// Private reply: satu per komentar, maksimal 7 hari setelah komentar
+		private := p.CommentID != ""
+		var deadline time.Time
+		prKey := "ig:private_reply:" + p.CommentID
+		if private {
+			if !p.CommentedAt.IsZero() {
+				deadline = p.CommentedAt.Add(ig.PrivateReplyWindow)
+				if time.Now().After(deadline) {
+					recordDrop(ctx, d.KV, "dm", taskKey, "private reply window (7d) expired for comment "+p.CommentID)
+					return nil
+				}
+			}
+			done, err := d.KV.Exists(ctx, prKey)
+			if err != nil {
+				return err
+			}
+			if done {
+				log.Printf("[SKIP] private reply already sent comment=%s", p.CommentID)
+				return nil
+			}
+		}
+
+		if err := checkSuspended(ctx, d, t, "dm", taskKey, p.IGBusinessID, p.CreatedAt, deadline); err != nil {
+			return err
+		}
+
+		// Jangan kirim saat quiet hours brand
+		if err := deferQuietHours(ctx, d, "dm", taskKey, p.CreatedAt, deadline, p.Timezone, p.QuietStart, p.QuietEnd); err != nil {
+			return err
+		}
+
+		token, err := resolveToken(ctx, d, p.IGBusinessID, p.IGToken)
+		if err != nil {
+			if repo.IsTokenUnavailable(err) {
+				return parkTask(ctx, d, t, "dm", taskKey, p.IGBusinessID, err.Error())
+			}
+			return err
+		}
+
+		// Kuota Meta (X-App-Usage / X-Business-Use-Case-Usage)
+		if err := checkUsage(ctx, d, "dm", taskKey, p.IGBusinessID, p.CreatedAt, deadline); err != nil {
+			return err
+		}
+
+		// Rate limit berlapis: workflow (SafetyCombinedLimits), brand, akun IG
+		wfLimits := rate.LayerLimits{MaxHour: p.MaxPerHour, MaxDay: p.MaxPerDay}
+		loc := rate.LoadLocation(p.Timezone)
+		dec, res, err := lim.Reserve(ctx, budgets(ctx, d, lim, p.BrandID, p.IGBusinessID, p.WorkflowID, wfLimits, p.SafetyDisabled, loc)...)
 		if err != nil {
 			return err
 		}
-		if !allow {
-			log.Printf("[RL] dm throttled brand=%s hour=%d day=%d", p.BrandID, hc, dc)
-			return asynq.SkipRetry // skip saja, job ini selesai
+		if !dec.Allowed {
+			// jangan buang task: jadwalkan ulang ke window berikutnya
+			return deferOrDrop(ctx, d, "dm", taskKey, p.CreatedAt, deadline, dec)
 		}
 
-		client := ig.NewClient(p.IGToken)
-		if err := client.SendDM(ctx, p.RecipientIGUserID, p.Message); err != nil {
-			// TODO: mapping error: kalau permission denied → bisa fallback ke public reply alternatif
+		// Klaim cooldown secara atomik sebelum kirim: task paralel untuk user
+		// yang sama tidak bisa lolos cek cooldown di atas bersamaan
+		cdToken, claimed, err := lim.ClaimCooldown(ctx, cdKey, cd.Duration)
+		if err != nil {
+			_ = lim.Refund(res)
 			return err
 		}
+		if !claimed {
+			_ = lim.Refund(res)
+			log.Printf("[SKIP] DM cooldown brand=%s user=%s", p.BrandID, p.RecipientIGUserID)
+			return nil
+		}
 
-		// Set cooldown setelah sukses
-		_ = lim.SetCooldown(ctx, p.BrandID, p.RecipientIGUserID, 24*time.Hour)
+		// Private reply: klaim komentar (SET NX) sebelum kirim supaya task paralel
+		// tidak membalas komentar yang sama dua kali
+		var prToken string
+		if private {
+			prToken = uuid.NewString()
+			ok, err := d.KV.SetNX(ctx, prKey, prToken, 2*ig.PrivateReplyWindow)
+			if err != nil || !ok {
+				_ = lim.Refund(res)
+				_ = lim.ReleaseCooldown(cdKey, cdToken)
+				if err != nil {
+					return err
+				}
+				log.Printf("[SKIP] private reply already claimed comment=%s", p.CommentID)
+				return nil
+			}
+		}
+
+		client := newIGClient(d, token)
+		to := ig.Recipient{CommentID: p.CommentID}
+		if !private {
+			// task lama tanpa CommentID: DM langsung ke IG user id
+			to = ig.Recipient{ID: p.RecipientIGUserID}
+		}
+		sendErr := sendDM(ctx, client, to, p)
+		if sendErr != nil {
+			// kuota & cooldown dikembalikan karena DM tidak terkirim
+			_ = lim.Refund(res)
+			if err := lim.ReleaseCooldown(cdKey, cdToken); err != nil {
+				log.Printf("[WARN] release cooldown key=%s: %v", cdKey, err)
+			}
+			if private {
+				releasePrivateReply(d, prKey, prToken)
+			}
+			return handleGraphError(ctx, d, t, "dm", taskKey, p.IGBusinessID, p.CreatedAt, deadline, sendErr)
+		}
+		if err := lim.Commit(ctx, res); err != nil {
+			log.Printf("[WARN] commit rate reservation: %v", err)
+		}
 
 		log.Printf("[OK] DM sent to user=%s", p.RecipientIGUserID)
 		return nil
 	})
 }
+
+// releasePrivateReply melepas klaim private reply yang gagal terkirim (hanya
+// kalau klaim masih milik task ini), dengan context baru.
+func releasePrivateReply(d Deps, key, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := d.KV.DelIfEqual(ctx, key, token); err != nil {
+		log.Printf("[WARN] release private reply key=%s: %v", key, err)
+	}
+}
+
+// sendDM mengirim pesan rich kalau ada; kalau ditolak Graph API (template
+// tidak didukung dsb.) fallback ke render teks.
+func sendDM(ctx context.Context, client *ig.Client, to ig.Recipient, p queue.TaskSendDMPayload) error {
+	if p.Rich != nil {
+		err := client.SendMessage(ctx, to, *p.Rich)
+		if err == nil || !errors.Is(err, ig.ErrRejected) {
+			return err
+		}
+		log.Printf("[WARN] rich DM rejected, fallback to text comment=%s: %v", p.CommentID, err)
+	}
+	return client.SendMessage(ctx, to, ig.TextMessage(p.Message))
+}
@@ -0,0 +1,230 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/store"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeGraph mencatat request ke Graph API dan membalas dengan respond.
+type fakeGraph struct {
+	mu       sync.Mutex
+	requests []map[string]json.RawMessage
+	respond  func(path string, body map[string]json.RawMessage) (int, string)
+}
+
+func (f *fakeGraph) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body map[string]json.RawMessage
+	b, _ := io.ReadAll(req.Body)
+	_ = json.Unmarshal(b, &body)
+	f.mu.Lock()
+	f.requests = append(f.requests, body)
+	f.mu.Unlock()
+	status, out := http.StatusOK, `{"id":"m1"}`
+	if f.respond != nil {
+		status, out = f.respond(req.URL.Path, body)
+	}
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(out))}, nil
+}
+
+func (f *fakeGraph) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+func (f *fakeGraph) client() *ig.Client {
+	return f.newClient("tok")
+}
+
+func (f *fakeGraph) newClient(token string) *ig.Client {
+	c := ig.NewClient(token)
+	c.HTTP = &http.Client{Transport: f}
+	return c
+}
+
+// recipients: field recipient dari setiap request SendMessage.
+func (f *fakeGraph) recipients() []ig.Recipient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []ig.Recipient
+	for _, r := range f.requests {
+		var to ig.Recipient
+		if json.Unmarshal(r["recipient"], &to) == nil && (to.ID != "" || to.CommentID != "") {
+			out = append(out, to)
+		}
+	}
+	return out
+}
+
+// newHandlerDeps: Deps dengan Redis miniredis dan Graph API palsu.
+func newHandlerDeps(t *testing.T) (Deps, *miniredis.Miniredis, *fakeGraph) {
+	mr := miniredis.RunT(t)
+	g := &fakeGraph{}
+	d := Deps{
+		KV:            store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})),
+		MaxStaleness:  12 * time.Hour,
+		FallbackToken: "tok",
+		NewClient:     g.newClient,
+	}
+	return d, mr, g
+}
+
+// runTask menjalankan task lewat mux (termasuk middleware) seperti di server.
+func runTask(t *testing.T, d Deps, typ string, payload any) error {
+	t.Helper()
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := asynq.NewServeMux()
+	RegisterHandlers(mux, d)
+	return mux.ProcessTask(context.Background(), asynq.NewTask(typ, b))
+}
+
+func dmPayload() queue.TaskSendDMPayload {
+	return queue.TaskSendDMPayload{
+		BrandID:           "b1",
+		IGBusinessID:      "a1",
+		RecipientIGUserID: "u1",
+		CommentID:         "c1",
+		CommentedAt:       time.Now().Add(-time.Hour),
+		PostID:            "p1",
+		Message:           "hi",
+		WorkflowID:        "w1",
+		NodeID:            "n1",
+		MaxPerHour:        10,
+		MaxPerDay:         100,
+		CreatedAt:         time.Now(),
+	}
+}
+
+func TestSendDMFallbackOnlyOnRejectedPayload(t *testing.T) {
+	rich := ig.ButtonTemplate("hi", []ig.Button{{Type: "web_url", Title: "Go", URL: "https://x"}})
+	p := queue.TaskSendDMPayload{CommentID: "c1", Message: "hi", Rich: &rich}
+	to := ig.Recipient{CommentID: "c1"}
+
+	// di luar jendela 7 hari: teks juga akan ditolak, jangan fallback
+	g := &fakeGraph{respond: func(string, map[string]json.RawMessage) (int, string) {
+		return 400, `{"error":{"message":"outside of allowed window","code":10,"error_subcode":2534022}}`
+	}}
+	err := sendDM(context.Background(), g.client(), to, p)
+	if err == nil || errors.Is(err, ig.ErrRejected) {
+		t.Fatalf("expected non-rejected error, got %v", err)
+	}
+	if g.count() != 1 {
+		t.Fatalf("outside window: %d requests, want 1 (no text fallback)", g.count())
+	}
+
+	// template ditolak (invalid parameter): fallback ke teks
+	g = &fakeGraph{respond: func(_ string, body map[string]json.RawMessage) (int, string) {
+		if strings.Contains(string(body["message"]), "attachment") {
+			return 400, `{"error":{"message":"Invalid parameter","code":100}}`
+		}
+		return 200, `{}`
+	}}
+	if err := sendDM(context.Background(), g.client(), to, p); err != nil {
+		t.Fatalf("fallback: %v", err)
+	}
+	if g.count() != 2 {
+		t.Fatalf("rejected template: %d requests, want 2", g.count())
+	}
+}
+
+func TestDMRichFallbackIsSinglePrivateReply(t *testing.T) {
+	d, mr, g := newHandlerDeps(t)
+	g.respond = func(_ string, body map[string]json.RawMessage) (int, string) {
+		if strings.Contains(string(body["message"]), "attachment") {
+			return 400, `{"error":{"message":"Invalid parameter","code":100}}`
+		}
+		return 200, `{}`
+	}
+	card := ig.GenericTemplate(ig.GenericElement{Title: "hi", ImageURL: "https://x/a.jpg"})
+	p := dmPayload()
+	p.Rich = &card
+
+	if err := runTask(t, d, queue.TypeSendDM, p); err != nil {
+		t.Fatalf("dm: %v", err)
+	}
+	// kartu ditolak → teks, keduanya ke komentar; tidak ada pesan ke IG user id
+	got := g.recipients()
+	if len(got) != 2 {
+		t.Fatalf("requests %v, want card + text fallback", got)
+	}
+	for _, to := range got {
+		if to.CommentID != "c1" || to.ID != "" {
+			t.Fatalf("message sent to %+v, want private reply to comment", to)
+		}
+	}
+	if !mr.Exists("ig:private_reply:c1") {
+		t.Fatal("private reply not claimed after send")
+	}
+}
+
+func TestDMPrivateReplyWindowExpired(t *testing.T) {
+	d, mr, g := newHandlerDeps(t)
+	p := dmPayload()
+	p.CommentedAt = time.Now().Add(-ig.PrivateReplyWindow - time.Hour)
+
+	if err := runTask(t, d, queue.TypeSendDM, p); err != nil {
+		t.Fatalf("dm: %v", err)
+	}
+	if g.count() != 0 {
+		t.Fatalf("%d requests after the 7-day window, want 0", g.count())
+	}
+	if !mr.Exists("task:dropped:dm:w1:n1:u1") {
+		t.Fatal("expired private reply not recorded as dropped")
+	}
+}
+
+func TestDMSkipsAlreadyClaimedComment(t *testing.T) {
+	d, mr, g := newHandlerDeps(t)
+	// task lain sudah membalas/mengklaim komentar ini
+	mr.Set("ig:private_reply:c1", "other-task")
+
+	if err := runTask(t, d, queue.TypeSendDM, dmPayload()); err != nil {
+		t.Fatalf("dm: %v", err)
+	}
+	if g.count() != 0 {
+		t.Fatalf("%d requests for an already claimed comment, want 0", g.count())
+	}
+	if v, _ := mr.Get("ig:private_reply:c1"); v != "other-task" {
+		t.Fatalf("foreign claim changed: %q", v)
+	}
+}
+
+func TestDMReleasesClaimAfterSendError(t *testing.T) {
+	d, mr, g := newHandlerDeps(t)
+	g.respond = func(string, map[string]json.RawMessage) (int, string) {
+		return 500, transientGraphError
+	}
+
+	if err := runTask(t, d, queue.TypeSendDM, dmPayload()); err == nil {
+		t.Fatal("expected retryable send error")
+	}
+	if g.count() != 1 {
+		t.Fatalf("%d requests, want 1", g.count())
+	}
+	// retry berikutnya harus bisa mengklaim ulang komentar & cooldown
+	if mr.Exists("ig:private_reply:c1") {
+		t.Fatal("private reply claim kept after send error")
+	}
+	for _, k := range mr.Keys() {
+		if strings.HasPrefix(k, "cooldown:") {
+			t.Fatalf("cooldown %s kept after send error", k)
+		}
+	}
+}
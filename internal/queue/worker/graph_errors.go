@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/repo"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Jeda default kalau Graph API membalas rate limit
+const graphRateLimitBackoff = 15 * time.Minute
+
+// handleGraphError memetakan klasifikasi error Graph API ke perilaku task:
+//   - retryable         → return err (retry asynq dengan backoff)
+//   - rate_limited      → defer tanpa menghabiskan retry (drop kalau lewat
+//     MaxStaleness/deadline)
+//   - token_invalid /
+//     permission_denied → (izin level app) simpan status integrasi + event + park task
+//   - permanent         → catat alasan + SkipRetry (termasuk izin per user)
+func handleGraphError(ctx context.Context, d Deps, t *asynq.Task, kind, taskKey, igBusinessID string, createdAt, deadline time.Time, err error) error {
+	class := ig.Classify(err)
+	switch class {
+	case ig.ClassRetryable:
+		return err
+	case ig.ClassRateLimited:
+		until := time.Now().Add(graphRateLimitBackoff)
+		if derr := deferUntil(ctx, d, kind, taskKey, createdAt, deadline, until, "graph api rate limited"); derr != nil {
+			return derr
+		}
+		log.Printf("[RL] %s graph rate limited key=%s until=%s: %v", kind, taskKey, until.UTC().Format(time.RFC3339), err)
+		return queue.Defer(until, "graph api rate limited")
+	case ig.ClassTokenInvalid, ig.ClassPermissionDenied:
+		suspendIntegration(ctx, d, igBusinessID, class, err)
+		return parkTask(ctx, d, t, kind, taskKey, igBusinessID, string(class)+": "+err.Error())
+	default:
+		recordDrop(ctx, d.KV, kind, taskKey, err.Error())
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+}
+
+// statusForClass: token dicabut → brand perlu connect ulang; izin app
+// dicabut → integrasi di-suspend.
+func statusForClass(class ig.ErrorClass) repo.IntegrationStatus {
+	if class == ig.ClassTokenInvalid {
+		return repo.StatusNeedsReauth
+	}
+	return repo.StatusSuspended
+}
+
+// suspendIntegration menyimpan status integrasi (needs_reauth/suspended) dan
+// meng-emit event saat status berubah; task lain untuk akun ini langsung di-skip.
+func suspendIntegration(ctx context.Context, d Deps, igBusinessID string, class ig.ErrorClass, cause error) {
+	if igBusinessID == "" || d.Status == nil {
+		return
+	}
+	status := statusForClass(class)
+	changed, err := d.Status.SetStatus(ctx, igBusinessID, status, cause.Error())
+	if err != nil {
+		log.Printf("[ERR] set integration status acct=%s status=%s: %v", igBusinessID, status, err)
+		return
+	}
+	if !changed {
+		return
+	}
+	log.Printf("[SUSPEND] integration acct=%s status=%s class=%s: %v", igBusinessID, status, class, cause)
+	queue.EmitIntegrationStatusChanged(ctx, d.Queue, queue.IntegrationStatusChangedPayload{
+		IGBusinessID: igBusinessID,
+		Status:       string(status),
+		Class:        string(class),
+		Reason:       cause.Error(),
+		At:           time.Now().UTC(),
+	})
+}
+
+// checkSuspended: task untuk integrasi yang tidak aktif di-park (revoked: di-drop).
+// Error lookup menunda task (bukan fail open).
+func checkSuspended(ctx context.Context, d Deps, t *asynq.Task, kind, taskKey, igBusinessID string, createdAt, deadline time.Time) error {
+	if igBusinessID == "" || d.Status == nil {
+		return nil
+	}
+	status, err := d.Status.Status(ctx, igBusinessID)
+	if err != nil {
+		// status tidak diketahui: jangan kirim ke akun yang mungkin suspended, tunda
+		until := time.Now().Add(time.Minute + time.Duration(rand.Intn(60))*time.Second)
+		if dropErr := deferUntil(ctx, d, kind, taskKey, createdAt, deadline, until, "integration status unavailable"); dropErr != nil {
+			return dropErr
+		}
+		log.Printf("[WARN] %s integration status acct=%s, deferred: %v", kind, igBusinessID, err)
+		return queue.Defer(until, "integration status unavailable")
+	}
+	if status == repo.StatusRevoked {
+		// user mencabut app: task tidak akan pernah bisa jalan lagi
+		recordDrop(ctx, d.KV, kind, taskKey, "integration revoked acct="+igBusinessID)
+		return fmt.Errorf("integration revoked acct=%s: %w", igBusinessID, asynq.SkipRetry)
+	}
+	if !status.Usable() {
+		return parkTask(ctx, d, t, kind, taskKey, igBusinessID, "integration "+string(status))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// withStatus memasang IntegrationStatusLookup dengan Postgres yang tidak
+// bisa dihubungi: status hanya terbaca dari cache ig:status:<acct>.
+func withStatus(t *testing.T, d *Deps) {
+	t.Helper()
+	pool, err := pgxpool.New(context.Background(), "postgres://u@127.0.0.1:1/x?connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	d.Status = repo.NewIntegrationStatusLookup(d.KV, pool)
+}
+
+func TestDMParkedWhenIntegrationSuspended(t *testing.T) {
+	d, mr, g := newHandlerDeps(t)
+	withStatus(t, &d)
+	d.Parked = queue.NewParker(d.KV, nil)
+	mr.Set("ig:status:a1", string(repo.StatusSuspended))
+
+	// task di-park dianggap selesai oleh middleware (tidak di-retry)
+	if err := runTask(t, d, queue.TypeSendDM, dmPayload()); err != nil {
+		t.Fatalf("dm: %v", err)
+	}
+	if g.count() != 0 {
+		t.Fatalf("%d requests for a suspended integration, want 0", g.count())
+	}
+	parked, err := d.Parked.List(context.Background(), "a1")
+	if err != nil || len(parked) != 1 || parked[0].Type != queue.TypeSendDM {
+		t.Fatalf("parked %+v %v, want the DM task", parked, err)
+	}
+}
+
+func TestDMDeferredWhenStatusLookupFails(t *testing.T) {
+	d, _, g := newHandlerDeps(t)
+	withStatus(t, &d)
+
+	err := runTask(t, d, queue.TypeSendDM, dmPayload())
+	var de *queue.DeferError
+	if !errors.As(err, &de) || de.Until.Before(time.Now()) {
+		t.Fatalf("got %v, want a deferral", err)
+	}
+	if g.count() != 0 {
+		t.Fatalf("%d requests with unknown integration status, want 0", g.count())
+	}
+}
+
+func TestDMDroppedPastMaxStaleness(t *testing.T) {
+	d, mr, g := newHandlerDeps(t)
+	withStatus(t, &d)
+	p := dmPayload()
+	p.CreatedAt = time.Now().Add(-d.MaxStaleness)
+
+	// defer berikutnya melewati MaxStaleness: drop, bukan defer lagi
+	if err := runTask(t, d, queue.TypeSendDM, p); !errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("got %v, want drop", err)
+	}
+	if g.count() != 0 {
+		t.Fatalf("%d requests for a stale task, want 0", g.count())
+	}
+	if !mr.Exists("task:dropped:dm:w1:n1:u1") {
+		t.Fatal("stale drop not recorded")
+	}
+}
@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ig-webhook/internal/queue"
+	"log"
+
+	"github.com/hibiken/asynq"
+)
+
+// registerIntegrationEventHandler menangani event status integrasi. Event
+// terakhir disimpan di Redis (integration:event:<acct>) untuk dibaca
+// dashboard/notifikasi brand ("hubungkan ulang Instagram").
+func registerIntegrationEventHandler(mux *asynq.ServeMux, d Deps) {
+	mux.HandleFunc(queue.TypeIntegrationStatusChanged, func(ctx context.Context, t *asynq.Task) error {
+		var p queue.IntegrationStatusChangedPayload
+		if err := json.Unmarshal(t.Payload(), &p); err != nil {
+			return err
+		}
+		if err := d.KV.Set(ctx, "integration:event:"+p.IGBusinessID, string(t.Payload()), 0); err != nil {
+			return err
+		}
+		log.Printf("[NOTIFY] integration acct=%s status=%s class=%s: %s", p.IGBusinessID, p.Status, p.Class, p.Reason)
+		return nil
+	})
+}
+
+// registerPurgeHandler menjalankan penghapusan data akun (data deletion callback).
+func registerPurgeHandler(mux *asynq.ServeMux, d Deps) {
+	mux.HandleFunc(queue.TypePurgeAccountData, func(ctx context.Context, t *asynq.Task) error {
+		var p queue.PurgeAccountDataPayload
+		if err := json.Unmarshal(t.Payload(), &p); err != nil {
+			return err
+		}
+		if d.Deletion == nil {
+			return fmt.Errorf("data deletion not configured: %w", asynq.SkipRetry)
+		}
+		err := d.Deletion.Purge(ctx, p)
+		if err != nil && (errors.Is(err, asynq.SkipRetry) || isLastAttempt(ctx)) {
+			// task akan di-archive: status URL tidak boleh tetap pending
+			if serr := d.Deletion.MarkFailed(ctx, p, err); serr != nil {
+				log.Printf("[ERR] save deletion status code=%s: %v", p.ConfirmationCode, serr)
+			}
+		}
+		return err
+	})
+}
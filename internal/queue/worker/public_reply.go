@@ -3,16 +3,18 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/hibiken/asynq"
-	"ig-webhook/internal/ig"
+	"ig-webhook/internal/processor"
 	"ig-webhook/internal/queue"
 	"ig-webhook/internal/rate"
-	"ig-webhook/internal/store"
+	"ig-webhook/internal/repo"
 	"log"
+	"time"
 )
 
-func registerPublicReplyHandler(mux *asynq.ServeMux, kv *store.RedisStore) {
-	lim := rate.NewLimiter(kv)
+func registerPublicReplyHandler(mux *asynq.ServeMux, d Deps) {
+	lim := rate.NewLimiter(d.KV)
 
 	mux.HandleFunc(queue.TypeSendPublicReply, func(ctx context.Context, t *asynq.Task) error {
 		var p queue.TaskSendPublicReplyPayload
@@ -20,26 +22,95 @@ func registerPublicReplyHandler(mux *asynq.ServeMux, kv *store.RedisStore) {
 			return err
 		}
 
-		// Rate limit per brand per action (contoh angka default 25/jam, 200/hari)
-		allow, hc, dc, err := lim.CheckAndIncr(ctx, p.BrandID, "public_reply", 25, 200)
+		taskKey := p.WorkflowID + ":" + p.NodeID + ":" + p.CommentID
+
+		// Reply sudah terkirim di percobaan sebelumnya (mis. enqueue DM gagal):
+		// jangan reply ulang, cukup lanjutkan rantai.
+		sentKey := "idem:reply_sent:" + taskKey
+		sent, err := d.KV.Exists(ctx, sentKey)
 		if err != nil {
 			return err
 		}
-		if !allow {
-			log.Printf("[RL] public_reply throttled brand=%s hour=%d day=%d", p.BrandID, hc, dc)
-			// reschedule sebentar, biar antrian lanjut
-			return asynq.SkipRetry // skip saja, job ini selesai
+		if sent {
+			return enqueueChainedDM(ctx, d, p, "resume")
+		}
+
+		// Cooldown DM juga menahan public reply kalau workflow memintanya
+		if cd := p.Cooldown(); cd.SuppressPublicReply && p.FromIGUserID != "" {
+			cooling, err := lim.IsCoolingDown(ctx, cd.Key(p.BrandID, p.WorkflowID, p.PostID, p.FromIGUserID))
+			if err != nil {
+				return err
+			}
+			if cooling {
+				log.Printf("[SKIP] public reply cooldown brand=%s user=%s", p.BrandID, p.FromIGUserID)
+				return nil
+			}
+		}
+
+		if err := checkSuspended(ctx, d, t, "public_reply", taskKey, p.IGBusinessID, p.CreatedAt, time.Time{}); err != nil {
+			return err
+		}
+
+		// Jangan kirim saat quiet hours brand
+		if err := deferQuietHours(ctx, d, "public_reply", taskKey, p.CreatedAt, time.Time{}, p.Timezone, p.QuietStart, p.QuietEnd); err != nil {
+			return err
+		}
+
+		token, err := resolveToken(ctx, d, p.IGBusinessID, p.IGToken)
+		if err != nil {
+			if repo.IsTokenUnavailable(err) {
+				return parkTask(ctx, d, t, "public_reply", taskKey, p.IGBusinessID, err.Error())
+			}
+			return err
 		}
 
-		client := ig.NewClient(p.IGToken) // gunakan graph.instagram.com untuk GET; reply perlu FB Graph
-		if err := client.ReplyComment(ctx, p.CommentID, p.Message); err != nil {
-			// biarkan Asynq retry dengan backoff
+		// Kuota Meta (X-App-Usage / X-Business-Use-Case-Usage)
+		if err := checkUsage(ctx, d, "public_reply", taskKey, p.IGBusinessID, p.CreatedAt, time.Time{}); err != nil {
 			return err
 		}
 
+		// Rate limit berlapis: workflow (SafetyCombinedLimits), brand, akun IG
+		wfLimits := rate.LayerLimits{MaxHour: p.MaxPerHour, MaxDay: p.MaxPerDay}
+		loc := rate.LoadLocation(p.Timezone)
+		dec, res, err := lim.Reserve(ctx, budgets(ctx, d, lim, p.BrandID, p.IGBusinessID, p.WorkflowID, wfLimits, p.SafetyDisabled, loc)...)
+		if err != nil {
+			return err
+		}
+		if !dec.Allowed {
+			// jangan buang task: jadwalkan ulang ke window berikutnya
+			return deferOrDrop(ctx, d, "public_reply", taskKey, p.CreatedAt, time.Time{}, dec)
+		}
+
+		client := newIGClient(d, token) // gunakan graph.instagram.com untuk GET; reply perlu FB Graph
+		replyID, err := client.ReplyComment(ctx, p.CommentID, p.Message)
+		if err != nil {
+			// kuota dikembalikan; retry/defer/skip sesuai klasifikasi error
+			_ = lim.Refund(res)
+			mapped := handleGraphError(ctx, d, t, "public_reply", taskKey, p.IGBusinessID, p.CreatedAt, time.Time{}, err)
+			// gagal final: DM tetap jalan kalau kebijakan workflow mengizinkan
+			final := errors.Is(mapped, asynq.SkipRetry) ||
+				(queue.IsFailure(mapped) && !errors.Is(mapped, errParked) && isLastAttempt(ctx))
+			if p.DMOnReplyFailure && final {
+				if cerr := enqueueChainedDM(ctx, d, p, "reply failed"); cerr != nil {
+					log.Printf("[ERR] chain DM after failed reply comment=%s: %v", p.CommentID, cerr)
+				}
+			}
+			return mapped
+		}
+		if err := lim.Commit(ctx, res); err != nil {
+			log.Printf("[WARN] commit rate reservation: %v", err)
+		}
+		if err := d.KV.Set(ctx, sentKey, "1", 7*24*time.Hour); err != nil {
+			log.Printf("[WARN] mark reply sent key=%s: %v", sentKey, err)
+		}
+		// balasan ke reply bot dikenali sebagai balasan ke komentar brand
+		if replyID != "" {
+			if err := d.KV.Set(ctx, processor.OwnCommentKey(p.IGBusinessID, replyID), "1", processor.OwnCommentTTL); err != nil {
+				log.Printf("[WARN] mark own comment %s: %v", replyID, err)
+			}
+		}
+
 		log.Printf("[OK] public reply sent comment=%s", p.CommentID)
-		return nil
+		return enqueueChainedDM(ctx, d, p, "reply sent")
 	})
 }
-
-var errRateLimited = asynq.SkipRetry // ganti sesuai kebutuhan; contoh: bisa juga retry dengan delay manual
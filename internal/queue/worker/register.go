@@ -2,12 +2,60 @@ package worker
 
 import (
 	"github.com/hibiken/asynq"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/rate"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/service"
 	"ig-webhook/internal/store"
+	"time"
 )
 
+// Deps berisi dependency bersama untuk semua handler task.
+type Deps struct {
+	KV    *store.RedisStore
+	Queue *asynq.Client // untuk task berantai (DM setelah public reply)
+
+	// Limit berlapis di atas limit per workflow
+	BrandLimits   rate.LayerLimits
+	AccountLimits rate.LayerLimits
+
+	// Task yang di-defer lebih lama dari ini (dihitung dari CreatedAt) di-drop
+	MaxStaleness time.Duration
+
+	// Hari lokal limit brand & akun IG kalau akun tidak punya timezone sendiri
+	DefaultTimezone string
+
+	// Warm-up limit akun IG baru (dihitung dari tanggal koneksi integrasi)
+	Warmup       rate.Warmup
+	Integrations *repo.IntegrationRepo
+
+	// Usage header Meta untuk throttling adaptif (nil = nonaktif)
+	Usage *rate.UsageTracker
+
+	// Status integrasi (active/needs_reauth/suspended); nil = tidak dicek
+	Status *repo.IntegrationStatusLookup
+
+	// Token IG di-resolve saat eksekusi (tidak dibawa di payload)
+	Tokens        service.TokenResolver // repo.IGTokenLookup
+	FallbackToken string                // dev: IG_PAGE_ACCESS_TOKEN (kosong di production)
+
+	// Task yang token-nya tidak bisa di-resolve di-park per akun (nil = drop)
+	Parked *queue.Parker
+
+	// Penghapusan data akun (data deletion callback Meta)
+	Deletion *service.DataDeletionService
+
+	// Client Graph API per token (nil = ig.NewClient)
+	NewClient func(token string) *ig.Client
+}
+
 // RegisterHandlers mengikat semua handler task ke mux asynq.
-func RegisterHandlers(mux *asynq.ServeMux, kv *store.RedisStore) {
+func RegisterHandlers(mux *asynq.ServeMux, d Deps) {
+	mux.Use(parkedMiddleware)
 	// inject dependency ke masing-masing file handler
-	registerPublicReplyHandler(mux, kv)
-	registerDMHandler(mux, kv)
+	registerPublicReplyHandler(mux, d)
+	registerDMHandler(mux, d)
+	registerIntegrationEventHandler(mux, d)
+	registerPurgeHandler(mux, d)
 }
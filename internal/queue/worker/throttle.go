@@ -0,0 +1,172 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/rate"
+	"ig-webhook/internal/store"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// jitter saat task digeser ke akhir quiet hours
+const quietJitter = 15 * time.Minute
+
+// budgets menyusun lapisan limit (workflow → brand → akun IG) untuk satu aksi.
+// Limit harian workflow mengikuti timezone workflow (wfLoc); brand & akun IG
+// dipakai bersama banyak workflow, jadi harinya ikut timezone akun (brand
+// dipetakan 1:1 ke akun). Limit akun IG diturunkan selama masa warm-up.
+func budgets(ctx context.Context, d Deps, lim *rate.Limiter, brandID, igBusinessID, workflowID string, wf rate.LayerLimits, safetyDisabled bool, wfLoc *time.Location) []rate.Budget {
+	var out []rate.Budget
+	if !safetyDisabled && workflowID != "" {
+		b := rate.WorkflowBudget(workflowID, wf)
+		b.Loc = wfLoc
+		out = append(out, b)
+	}
+	acctLoc := accountLocation(ctx, d, igBusinessID)
+	if brandID != "" {
+		b := rate.BrandBudget(brandID, d.BrandLimits)
+		b.Loc = acctLoc
+		out = append(out, b)
+	}
+	if igBusinessID != "" {
+		b := rate.AccountBudget(igBusinessID, accountLimits(ctx, d, lim, igBusinessID))
+		b.Loc = acctLoc
+		out = append(out, b)
+	}
+	return out
+}
+
+// accountLocation: timezone akun IG (cache Redis 24 jam), fallback DefaultTimezone.
+func accountLocation(ctx context.Context, d Deps, igBusinessID string) *time.Location {
+	if igBusinessID == "" {
+		return rate.LoadLocation(d.DefaultTimezone)
+	}
+	cacheKey := "ig:tz:" + igBusinessID
+	tz, err := d.KV.Get(ctx, cacheKey)
+	if err != nil {
+		if d.Integrations == nil {
+			return rate.LoadLocation(d.DefaultTimezone)
+		}
+		if tz, err = d.Integrations.AccountTimezone(ctx, igBusinessID); err != nil {
+			log.Printf("[WARN] integration timezone acct=%s: %v", igBusinessID, err)
+			return rate.LoadLocation(d.DefaultTimezone)
+		}
+		_ = d.KV.Set(ctx, cacheKey, tz, 24*time.Hour)
+	}
+	if tz == "" {
+		tz = d.DefaultTimezone
+	}
+	return rate.LoadLocation(tz)
+}
+
+// accountLimits menerapkan warm-up ke limit akun IG. Error lookup tidak
+// memblok pengiriman; pakai limit penuh.
+func accountLimits(ctx context.Context, d Deps, lim *rate.Limiter, igBusinessID string) rate.LayerLimits {
+	if d.Warmup.Days <= 0 {
+		return d.AccountLimits
+	}
+	start, ok, err := lim.WarmupStart(ctx, igBusinessID, connectedAt(ctx, d, igBusinessID))
+	if err != nil {
+		log.Printf("[WARN] warmup lookup acct=%s: %v", igBusinessID, err)
+		return d.AccountLimits
+	}
+	if !ok {
+		return d.AccountLimits
+	}
+	return d.Warmup.Apply(d.AccountLimits, start, time.Now())
+}
+
+// connectedAt membaca tanggal koneksi integrasi (cache Redis 24 jam).
+func connectedAt(ctx context.Context, d Deps, igBusinessID string) time.Time {
+	cacheKey := "ig:connected:" + igBusinessID
+	if raw, err := d.KV.Get(ctx, cacheKey); err == nil {
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t
+		}
+	}
+	if d.Integrations == nil {
+		return time.Time{}
+	}
+	t, err := d.Integrations.ConnectedAt(ctx, igBusinessID)
+	if err != nil {
+		log.Printf("[WARN] integration connected_at acct=%s: %v", igBusinessID, err)
+		return time.Time{}
+	}
+	_ = d.KV.Set(ctx, cacheKey, t.UTC().Format(time.RFC3339), 24*time.Hour)
+	return t
+}
+
+// deferQuietHours menunda task ke akhir quiet hours brand (dengan jitter).
+// Return nil kalau sekarang bukan quiet hours.
+func deferQuietHours(ctx context.Context, d Deps, kind, taskKey string, createdAt, deadline time.Time, tz, start, end string) error {
+	q, err := rate.ParseQuietHours(start, end, rate.LoadLocation(tz))
+	if err != nil {
+		log.Printf("[WARN] %s invalid quiet hours key=%s: %v", kind, taskKey, err)
+		return nil
+	}
+	now := time.Now()
+	if !q.Contains(now) {
+		return nil
+	}
+	until := q.Next(now, quietJitter)
+	if err := deferUntil(ctx, d, kind, taskKey, createdAt, deadline, until, "quiet hours"); err != nil {
+		return err
+	}
+	log.Printf("[QUIET] %s deferred key=%s until=%s", kind, taskKey, until.UTC().Format(time.RFC3339))
+	return queue.Defer(until, "quiet hours")
+}
+
+// deferOrDrop menjadwalkan ulang task ke awal window yang masih punya kuota,
+// atau men-drop task (dengan alasan tercatat) kalau sudah melewati
+// MaxStaleness atau deadline (zero = tanpa deadline).
+func deferOrDrop(ctx context.Context, d Deps, kind, taskKey string, createdAt, deadline time.Time, dec rate.Decision) error {
+	// jitter supaya task yang tertunda tidak jalan bersamaan di awal window
+	until := dec.RetryAt.Add(time.Duration(rand.Intn(120)) * time.Second)
+	if err := deferUntil(ctx, d, kind, taskKey, createdAt, deadline, until, "rate limited by "+dec.Scope); err != nil {
+		return err
+	}
+	log.Printf("[RL] %s deferred key=%s scope=%s until=%s", kind, taskKey, dec.Scope, until.UTC().Format(time.RFC3339))
+	return queue.Defer(until, "rate limited by "+dec.Scope)
+}
+
+// deferUntil: semua penundaan task (rate limit, quiet hours, usage Meta,
+// rate limit Graph) lewat sini. Return error drop (SkipRetry) kalau until
+// melewati MaxStaleness atau deadline; nil kalau task boleh di-defer.
+func deferUntil(ctx context.Context, d Deps, kind, taskKey string, createdAt, deadline, until time.Time, reason string) error {
+	var drop string
+	switch {
+	case d.MaxStaleness > 0 && !createdAt.IsZero() && until.Sub(createdAt) > d.MaxStaleness:
+		drop = fmt.Sprintf("%s until %s, exceeds max staleness %s",
+			reason, until.UTC().Format(time.RFC3339), d.MaxStaleness)
+	case !deadline.IsZero() && until.After(deadline):
+		drop = fmt.Sprintf("%s until %s, past deadline %s",
+			reason, until.UTC().Format(time.RFC3339), deadline.UTC().Format(time.RFC3339))
+	}
+	if drop == "" {
+		return nil
+	}
+	recordDrop(ctx, d.KV, kind, taskKey, drop)
+	return fmt.Errorf("%s: %w", drop, asynq.SkipRetry)
+}
+
+type dropRecord struct {
+	Kind   string    `json:"kind"`
+	Key    string    `json:"key"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// recordDrop menyimpan alasan task di-drop (7 hari) untuk ditelusuri.
+func recordDrop(ctx context.Context, kv *store.RedisStore, kind, taskKey, reason string) {
+	b, _ := json.Marshal(dropRecord{Kind: kind, Key: taskKey, Reason: reason, At: time.Now().UTC()})
+	if err := kv.Set(ctx, "task:dropped:"+kind+":"+taskKey, string(b), 7*24*time.Hour); err != nil {
+		log.Printf("[ERR] record drop %s key=%s: %v", kind, taskKey, err)
+	}
+	log.Printf("[DROP] %s key=%s reason=%s", kind, taskKey, reason)
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"ig-webhook/internal/rate"
+	"ig-webhook/internal/store"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestDeferUntilStalenessAndDeadline(t *testing.T) {
+	mr := miniredis.RunT(t)
+	d := Deps{KV: store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})), MaxStaleness: 12 * time.Hour}
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := deferUntil(ctx, d, "dm", "k1", now, time.Time{}, now.Add(time.Hour), "quiet hours"); err != nil {
+		t.Fatalf("short defer dropped: %v", err)
+	}
+	err := deferUntil(ctx, d, "dm", "k2", now.Add(-11*time.Hour), time.Time{}, now.Add(2*time.Hour), "graph api rate limited")
+	if !errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("expected drop past max staleness, got %v", err)
+	}
+	// deadline private reply 7 hari
+	err = deferUntil(ctx, d, "dm", "k3", now, now.Add(30*time.Minute), now.Add(time.Hour), "meta usage exhausted")
+	if !errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("expected drop past deadline, got %v", err)
+	}
+	if !mr.Exists("task:dropped:dm:k3") {
+		t.Fatal("drop reason not recorded")
+	}
+}
+
+func TestBudgetsTimezones(t *testing.T) {
+	mr := miniredis.RunT(t)
+	kv := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	d := Deps{KV: kv, DefaultTimezone: "Asia/Jakarta"}
+	lim := rate.NewLimiter(kv)
+	ctx := context.Background()
+	wfLoc := rate.LoadLocation("America/New_York")
+	limits := rate.LayerLimits{MaxHour: 1}
+
+	locs := func(acct string) map[string]string {
+		out := map[string]string{}
+		for _, b := range budgets(ctx, d, lim, "b1", acct, "w1", limits, false, wfLoc) {
+			out[b.Scope] = b.Loc.String()
+		}
+		return out
+	}
+	got := locs("a1")
+	if got["wf:w1"] != "America/New_York" || got["brand:b1"] != "Asia/Jakarta" || got["acct:a1"] != "Asia/Jakarta" {
+		t.Fatalf("default timezones: %v", got)
+	}
+
+	// timezone akun (cache) menang atas default, workflow tetap pakai timezone-nya
+	mr.Set("ig:tz:a2", "Europe/Berlin")
+	got = locs("a2")
+	if got["wf:w1"] != "America/New_York" || got["brand:b1"] != "Europe/Berlin" || got["acct:a2"] != "Europe/Berlin" {
+		t.Fatalf("account timezones: %v", got)
+	}
+}
@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/hibiken/asynq"
+)
+
+// resolveToken mengambil token integrasi saat task dieksekusi, jadi task yang
+// tertunda selalu memakai token terbaru setelah refresh.
+// legacyToken: token dari payload task lama (sebelum token dihapus dari payload).
+// Error repo.ErrTokenNotFound/ErrTokenExpired/ErrIntegrationSuspended diteruskan
+// supaya task di-park. FallbackToken hanya diisi di luar production.
+func resolveToken(ctx context.Context, d Deps, igBusinessID, legacyToken string) (string, error) {
+	if igBusinessID != "" && d.Tokens != nil {
+		token, err := d.Tokens.Lookup(ctx, igBusinessID)
+		if err == nil {
+			return token, nil
+		}
+		if d.FallbackToken == "" {
+			return "", fmt.Errorf("resolve token acct=%s: %w", igBusinessID, err)
+		}
+		log.Printf("[WARN] resolve token acct=%s: %v (using dev fallback token)", igBusinessID, err)
+		return d.FallbackToken, nil
+	}
+	// Task lama tanpa IGBusinessID: pakai token yang terbawa di payload
+	if legacyToken != "" {
+		return legacyToken, nil
+	}
+	if d.FallbackToken != "" {
+		return d.FallbackToken, nil
+	}
+	return "", fmt.Errorf("no integration reference to resolve token")
+}
+
+// errParked: task sudah disimpan di daftar park; parkedMiddleware
+// menganggapnya selesai (task dihapus dari asynq, bukan di-retry/archive).
+var errParked = errors.New("task parked")
+
+// parkTask menyimpan task untuk di-enqueue ulang setelah integrasi diperbaiki.
+// Tanpa Parker (atau tanpa akun IG) task di-drop.
+func parkTask(ctx context.Context, d Deps, t *asynq.Task, kind, taskKey, igBusinessID, reason string) error {
+	if d.Parked == nil || igBusinessID == "" {
+		recordDrop(ctx, d.KV, kind, taskKey, reason)
+		return fmt.Errorf("%s: %w", reason, asynq.SkipRetry)
+	}
+	if err := d.Parked.Park(ctx, igBusinessID, taskKey, t, reason); err != nil {
+		return fmt.Errorf("park %s key=%s: %w", kind, taskKey, err)
+	}
+	log.Printf("[PARK] %s key=%s acct=%s reason=%s", kind, taskKey, igBusinessID, reason)
+	return fmt.Errorf("%s: %w", reason, errParked)
+}
+
+func parkedMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		err := next.ProcessTask(ctx, t)
+		if errors.Is(err, errParked) {
+			return nil
+		}
+		return err
+	})
+}
@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/repo"
+	"testing"
+
+	"github.com/hibiken/asynq"
+)
+
+type fakeTokens struct {
+	token string
+	err   error
+}
+
+func (f fakeTokens) Lookup(context.Context, string) (string, error) { return f.token, f.err }
+
+func TestPublicReplyUsesTokenResolvedAtExecution(t *testing.T) {
+	d, _, g := newHandlerDeps(t)
+	d.FallbackToken = ""
+	d.Tokens = fakeTokens{token: "fresh-tok"}
+	p := replyPayload()
+	p.NextDM = nil
+	p.IGToken = "stale-tok" // task lama yang masih membawa token
+
+	if err := runTask(t, d, queue.TypeSendPublicReply, p); err != nil {
+		t.Fatalf("public reply: %v", err)
+	}
+	if g.count() != 1 {
+		t.Fatalf("%d requests, want 1", g.count())
+	}
+	var used string
+	_ = json.Unmarshal(g.requests[0]["access_token"], &used)
+	if used != "fresh-tok" {
+		t.Fatalf("reply sent with token %q, want the resolved token", used)
+	}
+}
+
+func TestDMParkedWhenTokenUnavailable(t *testing.T) {
+	d, mr, g := newHandlerDeps(t)
+	d.FallbackToken = ""
+	d.Tokens = fakeTokens{err: fmt.Errorf("%w: account=a1", repo.ErrTokenExpired)}
+
+	// tanpa Parker: drop (tercatat), jangan retry
+	if err := runTask(t, d, queue.TypeSendDM, dmPayload()); !errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("got %v, want drop without parker", err)
+	}
+	if !mr.Exists("task:dropped:dm:w1:n1:u1") {
+		t.Fatal("drop not recorded")
+	}
+
+	d.Parked = queue.NewParker(d.KV, nil)
+	if err := runTask(t, d, queue.TypeSendDM, dmPayload()); err != nil {
+		t.Fatalf("dm: %v", err)
+	}
+	if g.count() != 0 {
+		t.Fatalf("%d requests without a token, want 0", g.count())
+	}
+	parked, err := d.Parked.List(context.Background(), "a1")
+	if err != nil || len(parked) != 1 {
+		t.Fatalf("parked %+v %v, want 1 task", parked, err)
+	}
+	// komentar tidak diklaim: task bisa jalan setelah di-retry dari park
+	if mr.Exists("ig:private_reply:c1") {
+		t.Fatal("private reply claimed for a parked task")
+	}
+}
@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/rate"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// newIGClient membuat client yang mencatat header usage Meta ke Redis.
+func newIGClient(d Deps, token string) *ig.Client {
+	newClient := d.NewClient
+	if newClient == nil {
+		newClient = ig.NewClient
+	}
+	c := newClient(token)
+	if d.Usage == nil {
+		return c
+	}
+	c.OnUsage = d.Usage.Observe
+	return c
+}
+
+// checkUsage menunda task kalau kuota Meta (app / akun IG) hampir atau sudah habis.
+func checkUsage(ctx context.Context, d Deps, kind, taskKey, igBusinessID string, createdAt, deadline time.Time) error {
+	if d.Usage == nil {
+		return nil
+	}
+	scopes := []string{rate.AppUsageScope()}
+	if igBusinessID != "" {
+		scopes = append(scopes, rate.AccountUsageScope(igBusinessID))
+	}
+	v, err := d.Usage.Check(ctx, scopes...)
+	if err != nil {
+		// usage hanya optimasi; jangan blok pengiriman
+		log.Printf("[WARN] usage check %s key=%s: %v", kind, taskKey, err)
+		return nil
+	}
+	if !v.PauseUntil.IsZero() {
+		if err := deferUntil(ctx, d, kind, taskKey, createdAt, deadline, v.PauseUntil, "meta usage exhausted for "+v.Scope); err != nil {
+			return err
+		}
+		log.Printf("[USAGE] %s paused key=%s scope=%s until=%s", kind, taskKey, v.Scope, v.PauseUntil.UTC().Format(time.RFC3339))
+		return queue.Defer(v.PauseUntil, "meta usage exhausted for "+v.Scope)
+	}
+	if !d.Usage.Proceed(v) {
+		until := time.Now().Add(time.Minute + time.Duration(rand.Intn(240))*time.Second)
+		if err := deferUntil(ctx, d, kind, taskKey, createdAt, deadline, until, "meta usage high for "+v.Scope); err != nil {
+			return err
+		}
+		log.Printf("[USAGE] %s slowed key=%s scope=%s usage=%d%%", kind, taskKey, v.Scope, v.Percent)
+		return queue.Defer(until, "meta usage high for "+v.Scope)
+	}
+	return nil
+}
@@ -2,48 +2,233 @@ package rate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"ig-webhook/internal/store"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 )
 
 type Limiter struct {
-	kv *store.RedisStore
+	kv  *store.RedisStore
+	now func() time.Time
 }
 
 func NewLimiter(kv *store.RedisStore) *Limiter {
-	return &Limiter{kv: kv}
+	return &Limiter{kv: kv, now: time.Now}
+}
+
+// LayerLimits is an hourly/daily cap; 0 means unlimited.
+type LayerLimits struct {
+	MaxHour int
+	MaxDay  int
 }
 
-// returns allow bool, current count hour, current count day
-func (l *Limiter) CheckAndIncr(ctx context.Context, brandID, action string, maxHour, maxDay int) (bool, int64, int64, error) {
-	hourKey := fmt.Sprintf("rl:%s:%s:hour:%s", brandID, action, time.Now().UTC().Format("2006010215"))
-	dayKey := fmt.Sprintf("rl:%s:%s:day:%s", brandID, action, time.Now().UTC().Format("20060102"))
+// Budget is one layer of the limiter decision (workflow, brand, IG account).
+type Budget struct {
+	Scope string // e.g. "wf:<id>", "brand:<id>", "acct:<id>"
+	LayerLimits
+	// Loc makes the daily limit follow local calendar days (brand timezone).
+	// Nil keeps a sliding 24h window.
+	Loc *time.Location
+}
 
-	hc, err := l.kv.IncrWithTTL(ctx, hourKey, time.Hour+5*time.Minute)
+func WorkflowBudget(workflowID string, l LayerLimits) Budget {
+	return Budget{Scope: "wf:" + workflowID, LayerLimits: l}
+}
+
+func BrandBudget(brandID string, l LayerLimits) Budget {
+	return Budget{Scope: "brand:" + brandID, LayerLimits: l}
+}
+
+func AccountBudget(igBusinessID string, l LayerLimits) Budget {
+	return Budget{Scope: "acct:" + igBusinessID, LayerLimits: l}
+}
+
+// Decision is the combined result over all budgets.
+type Decision struct {
+	Allowed   bool
+	Scope     string // budget that rejected (empty when allowed)
+	HourCount int64  // counts of the rejecting budget
+	DayCount  int64
+	RetryAt   time.Time // earliest time the rejecting budget has free quota
+}
+
+// Reservation holds quota taken by Reserve until it is committed or refunded.
+// Reservations that are never committed expire after pendingTTL and give
+// their quota back (e.g. the worker crashed mid-call).
+type Reservation struct {
+	ID   string
+	keys []string
+}
+
+const pendingTTL = 2 * time.Minute
+
+// ErrReservationExpired: Commit came after pendingTTL and the reservation had
+// already been reclaimed. The action is still recorded, at commit time.
+var ErrReservationExpired = errors.New("rate reservation expired before commit")
+
+// Reserve atomically checks every budget over a sliding hour/day window and,
+// only if all of them have room, takes one unit from each. Budgets without a
+// scope or limits are skipped. A rejected attempt consumes nothing.
+func (l *Limiter) Reserve(ctx context.Context, budgets ...Budget) (Decision, *Reservation, error) {
+	now := l.now()
+	active := make([]Budget, 0, len(budgets))
+	for _, b := range budgets {
+		if b.Scope == "" || (b.MaxHour <= 0 && b.MaxDay <= 0) {
+			continue
+		}
+		active = append(active, b)
+	}
+	if len(active) == 0 {
+		return Decision{Allowed: true}, &Reservation{}, nil
+	}
+
+	res := &Reservation{ID: uuid.NewString()}
+	args := []interface{}{now.UnixMilli(), res.ID, pendingTTL.Milliseconds()}
+	for _, b := range active {
+		res.keys = append(res.keys, windowKey(b.Scope), pendingKey(b.Scope))
+		args = append(args, b.MaxHour, b.MaxDay,
+			now.Add(-time.Hour).UnixMilli(), dayStart(now, b.Loc).UnixMilli())
+	}
+
+	raw, err := l.kv.RunScript(ctx, reserveScript, res.keys, args...)
 	if err != nil {
-		return false, 0, 0, err
+		return Decision{}, nil, fmt.Errorf("reserve: %w", err)
+	}
+	out, ok := raw.([]interface{})
+	if !ok || len(out) == 0 {
+		return Decision{}, nil, fmt.Errorf("reserve: unexpected reply %v", raw)
+	}
+	idx, _ := out[0].(int64)
+	if idx == 0 {
+		return Decision{Allowed: true}, res, nil
+	}
+
+	// {idx, window(1=hour,2=day), hourCount, dayCount, oldestMs}
+	window, _ := out[1].(int64)
+	hc, _ := out[2].(int64)
+	dc, _ := out[3].(int64)
+	oldestMs, _ := out[4].(int64)
+	rejected := active[idx-1]
+	retryAt := time.UnixMilli(oldestMs).Add(time.Hour)
+	if window == 2 {
+		if rejected.Loc != nil {
+			retryAt = StartOfDay(now, rejected.Loc).AddDate(0, 0, 1)
+		} else {
+			retryAt = time.UnixMilli(oldestMs).Add(24 * time.Hour)
+		}
 	}
-	dc, err := l.kv.IncrWithTTL(ctx, dayKey, 24*time.Hour+30*time.Minute)
+	return Decision{
+		Allowed:   false,
+		Scope:     rejected.Scope,
+		HourCount: hc,
+		DayCount:  dc,
+		RetryAt:   retryAt,
+	}, nil, nil
+}
+
+func dayStart(now time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		return now.Add(-24 * time.Hour)
+	}
+	return StartOfDay(now, loc)
+}
+
+// Commit makes a reservation permanent (the action was performed). A
+// reservation already reclaimed by another Reserve is re-recorded and
+// reported as ErrReservationExpired.
+func (l *Limiter) Commit(ctx context.Context, r *Reservation) error {
+	if r == nil || len(r.keys) == 0 {
+		return nil
+	}
+	raw, err := l.kv.RunScript(ctx, commitScript, r.keys, r.ID, l.now().UnixMilli())
 	if err != nil {
-		return false, hc, 0, err
+		return err
+	}
+	if n, _ := raw.(int64); n > 0 {
+		return fmt.Errorf("commit %s (%d budgets): %w", r.ID, n, ErrReservationExpired)
 	}
+	return nil
+}
 
-	allow := (maxHour <= 0 || int(hc) <= maxHour) && (maxDay <= 0 || int(dc) <= maxDay)
-	return allow, hc, dc, nil
+// Refund gives the reserved quota back (e.g. the IG call failed). It uses a
+// fresh context so a cancelled task still releases its quota.
+func (l *Limiter) Refund(r *Reservation) error {
+	if r == nil || len(r.keys) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := l.kv.RunScript(ctx, refundScript, r.keys, r.ID)
+	return err
 }
 
-func (l *Limiter) SetCooldown(ctx context.Context, brandID, igUserID string, dur time.Duration) error {
-	key := fmt.Sprintf("cooldown:dm:%s:%s", brandID, igUserID)
-	return l.kv.Set(ctx, key, "1", dur)
+// Cooldown is a per-workflow DM cooldown policy.
+type Cooldown struct {
+	Scope               string // brand | workflow | post | none
+	Duration            time.Duration
+	SuppressPublicReply bool
 }
 
-func (l *Limiter) IsCoolingDown(ctx context.Context, brandID, igUserID string) (bool, error) {
-	key := fmt.Sprintf("cooldown:dm:%s:%s", brandID, igUserID)
-	_, err := l.kv.Get(ctx, key)
+// Key returns the Redis key for the recipient within the policy scope, or ""
+// when the scope is "none". Brand scope keeps the original key format.
+func (c Cooldown) Key(brandID, workflowID, postID, igUserID string) string {
+	switch c.Scope {
+	case "none":
+		return ""
+	case "workflow":
+		return fmt.Sprintf("cooldown:dm:wf:%s:%s", workflowID, igUserID)
+	case "post":
+		return fmt.Sprintf("cooldown:dm:post:%s:%s", postID, igUserID)
+	default:
+		return fmt.Sprintf("cooldown:dm:%s:%s", brandID, igUserID)
+	}
+}
+
+// ClaimCooldown atomically starts the cooldown (SET NX) before the DM is sent,
+// so two tasks for the same recipient cannot both pass the cooldown check.
+// It returns the claim token for ReleaseCooldown; ok=false means the recipient
+// is already cooling down. An empty key (scope "none") always succeeds.
+func (l *Limiter) ClaimCooldown(ctx context.Context, key string, dur time.Duration) (token string, ok bool, err error) {
+	if key == "" {
+		return "", true, nil
+	}
+	token = uuid.NewString()
+	ok, err = l.kv.SetNX(ctx, key, token, dur)
 	if err != nil {
-		// key not found
+		return "", false, fmt.Errorf("cooldown claim: %w", err)
+	}
+	return token, ok, nil
+}
+
+// ReleaseCooldown drops a claim whose DM was not sent (refund or failure). It
+// only deletes the key while it still holds token, and uses a fresh context so
+// a cancelled task still releases its claim.
+func (l *Limiter) ReleaseCooldown(key, token string) error {
+	if key == "" || token == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := l.kv.DelIfEqual(ctx, key, token)
+	return err
+}
+
+// IsCoolingDown fails closed: any Redis error other than a missing key is
+// returned (with cooling=true) so the caller retries instead of sending.
+func (l *Limiter) IsCoolingDown(ctx context.Context, key string) (bool, error) {
+	if key == "" {
+		return false, nil
+	}
+	_, err := l.kv.Get(ctx, key)
+	if errors.Is(err, redis.Nil) {
 		return false, nil
 	}
+	if err != nil {
+		return true, fmt.Errorf("cooldown lookup: %w", err)
+	}
 	return true, nil
 }
@@ -0,0 +1,162 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"ig-webhook/internal/store"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestLimiter(t *testing.T) (*Limiter, *miniredis.Miniredis, *time.Time) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	l := NewLimiter(store.NewRedisStore(rdb))
+	l.now = func() time.Time { return now }
+	return l, mr, &now
+}
+
+func TestLimiterReserveCommitRefund(t *testing.T) {
+	ctx := context.Background()
+	l, mr, _ := newTestLimiter(t)
+	b := BrandBudget("b1", LayerLimits{MaxHour: 2, MaxDay: 10})
+
+	dec, r1, err := l.Reserve(ctx, b)
+	if err != nil || !dec.Allowed {
+		t.Fatalf("first reserve: %+v %v", dec, err)
+	}
+	if err := l.Commit(ctx, r1); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := mr.ZMembers(pendingKey("brand:b1")); len(ids) != 0 {
+		t.Fatalf("committed id still pending: %v", ids)
+	}
+
+	dec, r2, err := l.Reserve(ctx, b)
+	if err != nil || !dec.Allowed {
+		t.Fatalf("second reserve: %+v %v", dec, err)
+	}
+	dec, _, err = l.Reserve(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.Allowed || dec.Scope != "brand:b1" || dec.HourCount != 2 {
+		t.Fatalf("expected hourly rejection, got %+v", dec)
+	}
+
+	// refund memberi kuota kembali
+	if err := l.Refund(r2); err != nil {
+		t.Fatal(err)
+	}
+	if dec, _, _ := l.Reserve(ctx, b); !dec.Allowed {
+		t.Fatalf("reserve after refund rejected: %+v", dec)
+	}
+}
+
+func TestLimiterRejectedReserveConsumesNothing(t *testing.T) {
+	ctx := context.Background()
+	l, mr, _ := newTestLimiter(t)
+	wf := WorkflowBudget("w1", LayerLimits{MaxHour: 5})
+	acct := AccountBudget("a1", LayerLimits{MaxHour: 1})
+
+	if dec, _, _ := l.Reserve(ctx, acct); !dec.Allowed {
+		t.Fatal("account reserve rejected")
+	}
+	dec, _, err := l.Reserve(ctx, wf, acct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.Allowed || dec.Scope != "acct:a1" {
+		t.Fatalf("expected account rejection, got %+v", dec)
+	}
+	if mr.Exists(windowKey("wf:w1")) {
+		t.Fatal("workflow budget consumed by rejected reservation")
+	}
+}
+
+func TestLimiterReclaimsStaleReservation(t *testing.T) {
+	ctx := context.Background()
+	l, mr, now := newTestLimiter(t)
+	b := AccountBudget("a1", LayerLimits{MaxHour: 1})
+
+	// reservasi tidak pernah di-commit (worker crash)
+	if dec, _, _ := l.Reserve(ctx, b); !dec.Allowed {
+		t.Fatal("reserve rejected")
+	}
+	if dec, _, _ := l.Reserve(ctx, b); dec.Allowed {
+		t.Fatal("pending reservation should still hold quota")
+	}
+
+	// :p harus hidup selama :w supaya reservasi basi tetap bisa dibersihkan
+	if wTTL, pTTL := mr.TTL(windowKey("acct:a1")), mr.TTL(pendingKey("acct:a1")); pTTL < wTTL {
+		t.Fatalf("pending ttl %v shorter than window ttl %v", pTTL, wTTL)
+	}
+	mr.FastForward(10 * time.Minute)
+	*now = now.Add(10 * time.Minute)
+	if !mr.Exists(pendingKey("acct:a1")) {
+		t.Fatal("pending set expired while window still holds its ids")
+	}
+
+	if dec, _, _ := l.Reserve(ctx, b); !dec.Allowed {
+		t.Fatalf("stale reservation not reclaimed: %+v", dec)
+	}
+}
+
+func TestLimiterCommitAfterReclaimReportsExpired(t *testing.T) {
+	ctx := context.Background()
+	l, mr, now := newTestLimiter(t)
+	b := AccountBudget("a1", LayerLimits{MaxHour: 2})
+
+	_, r1, _ := l.Reserve(ctx, b)
+	*now = now.Add(pendingTTL + time.Second)
+	// reservasi lain membersihkan r1 yang belum di-commit
+	if dec, _, _ := l.Reserve(ctx, b); !dec.Allowed {
+		t.Fatal("reserve rejected")
+	}
+	if err := l.Commit(ctx, r1); !errors.Is(err, ErrReservationExpired) {
+		t.Fatalf("late commit: got %v, want ErrReservationExpired", err)
+	}
+	// aksi r1 tetap tercatat di window
+	if ids, _ := mr.ZMembers(windowKey("acct:a1")); len(ids) != 2 {
+		t.Fatalf("window ids %v, want 2", ids)
+	}
+	if dec, _, _ := l.Reserve(ctx, b); dec.Allowed {
+		t.Fatal("late commit not counted toward the hourly limit")
+	}
+}
+
+func TestLimiterCooldownClaimRelease(t *testing.T) {
+	ctx := context.Background()
+	l, _, _ := newTestLimiter(t)
+	key := Cooldown{Scope: "brand"}.Key("b1", "", "", "u1")
+
+	tok, ok, err := l.ClaimCooldown(ctx, key, time.Hour)
+	if err != nil || !ok {
+		t.Fatalf("first claim: %v %v", ok, err)
+	}
+	if _, ok, _ := l.ClaimCooldown(ctx, key, time.Hour); ok {
+		t.Fatal("second claim must fail while cooling down")
+	}
+	// token orang lain tidak boleh melepas klaim
+	if err := l.ReleaseCooldown(key, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if cooling, _ := l.IsCoolingDown(ctx, key); !cooling {
+		t.Fatal("foreign token released the cooldown")
+	}
+	if err := l.ReleaseCooldown(key, tok); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := l.ClaimCooldown(ctx, key, time.Hour); !ok {
+		t.Fatal("claim after release rejected")
+	}
+	if _, ok, _ := l.ClaimCooldown(ctx, Cooldown{Scope: "none"}.Key("b1", "", "", "u1"), time.Hour); !ok {
+		t.Fatal("scope none must always claim")
+	}
+}
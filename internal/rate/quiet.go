@@ -0,0 +1,102 @@
+package rate
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// LoadLocation returns the IANA location, falling back to UTC when the name
+// is empty or unknown.
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// StartOfDay returns local midnight of t in loc.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	lt := t.In(loc)
+	return time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, loc)
+}
+
+// QuietHours is a daily local window in which no actions are sent.
+// The window may wrap midnight (e.g. 22:00-07:00). Start == End disables it.
+type QuietHours struct {
+	Start int // minutes since local midnight
+	End   int
+	Loc   *time.Location
+}
+
+// ParseQuietHours parses "HH:MM" bounds. Empty bounds give a disabled window.
+func ParseQuietHours(start, end string, loc *time.Location) (QuietHours, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	q := QuietHours{Loc: loc}
+	if start == "" || end == "" {
+		return q, nil
+	}
+	s, err := parseClock(start)
+	if err != nil {
+		return q, err
+	}
+	e, err := parseClock(end)
+	if err != nil {
+		return q, err
+	}
+	q.Start, q.End = s, e
+	return q, nil
+}
+
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid clock %q: %w", v, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (q QuietHours) Enabled() bool { return q.Start != q.End }
+
+// Contains reports whether t falls inside the quiet window.
+func (q QuietHours) Contains(t time.Time) bool {
+	if !q.Enabled() {
+		return false
+	}
+	lt := t.In(q.loc())
+	m := lt.Hour()*60 + lt.Minute()
+	if q.Start < q.End {
+		return m >= q.Start && m < q.End
+	}
+	return m >= q.Start || m < q.End
+}
+
+// Next returns t when it is outside quiet hours, otherwise the end of the
+// quiet window plus a random jitter in [0, jitter).
+func (q QuietHours) Next(t time.Time, jitter time.Duration) time.Time {
+	if !q.Contains(t) {
+		return t
+	}
+	day := StartOfDay(t, q.loc())
+	end := day.Add(time.Duration(q.End) * time.Minute)
+	if !end.After(t) {
+		end = day.AddDate(0, 0, 1).Add(time.Duration(q.End) * time.Minute)
+	}
+	if jitter > 0 {
+		end = end.Add(time.Duration(rand.Int63n(int64(jitter))))
+	}
+	return end
+}
+
+func (q QuietHours) loc() *time.Location {
+	if q.Loc == nil {
+		return time.UTC
+	}
+	return q.Loc
+}
@@ -0,0 +1,44 @@
+package rate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursWrapMidnight(t *testing.T) {
+	loc := time.FixedZone("WIB", 7*3600)
+	q, err := ParseQuietHours("22:00", "07:00", loc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	cases := []struct {
+		at    time.Time
+		quiet bool
+		next  time.Time
+	}{
+		{time.Date(2025, 1, 10, 21, 59, 0, 0, loc), false, time.Date(2025, 1, 10, 21, 59, 0, 0, loc)},
+		{time.Date(2025, 1, 10, 23, 30, 0, 0, loc), true, time.Date(2025, 1, 11, 7, 0, 0, 0, loc)},
+		{time.Date(2025, 1, 11, 3, 0, 0, 0, loc), true, time.Date(2025, 1, 11, 7, 0, 0, 0, loc)},
+		{time.Date(2025, 1, 11, 7, 0, 0, 0, loc), false, time.Date(2025, 1, 11, 7, 0, 0, 0, loc)},
+	}
+	for _, c := range cases {
+		if got := q.Contains(c.at); got != c.quiet {
+			t.Fatalf("Contains(%s) = %v, want %v", c.at, got, c.quiet)
+		}
+		if got := q.Next(c.at, 0); !got.Equal(c.next) {
+			t.Fatalf("Next(%s) = %s, want %s", c.at, got, c.next)
+		}
+	}
+}
+
+func TestQuietHoursDisabled(t *testing.T) {
+	q, err := ParseQuietHours("", "", nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	now := time.Now()
+	if q.Contains(now) || !q.Next(now, time.Minute).Equal(now) {
+		t.Fatalf("empty quiet hours must never defer")
+	}
+}
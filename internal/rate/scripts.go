@@ -0,0 +1,94 @@
+package rate
+
+import "github.com/redis/go-redis/v9"
+
+// Each budget owns two sorted sets:
+//   rl:<scope>:w  member=reservation id, score=reserved at (ms)
+//   rl:<scope>:p  member=reservation id, score=pending deadline (ms)
+// Entries in :w count toward the window; entries still in :p past their
+// deadline were never committed and are dropped from :w.
+//
+// Reserve/Commit/Refund touch the wf, brand and acct budgets in one EVAL.
+// Those keys hash to different slots and a brand budget spans several
+// accounts, so no single hash tag fits: the limiter requires a single-node
+// Redis (or a primary/replica setup), not Redis Cluster.
+
+func windowKey(scope string) string  { return "rl:" + scope + ":w" }
+func pendingKey(scope string) string { return "rl:" + scope + ":p" }
+
+// KEYS: (window, pending) per budget
+// ARGV: now, id, pendingTTL, then per budget: maxHour, maxDay, hourStart, dayStart
+var reserveScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local id = ARGV[2]
+local pendingTTL = tonumber(ARGV[3])
+local n = #KEYS / 2
+
+for i = 1, n do
+  local w = KEYS[2*i-1]
+  local p = KEYS[2*i]
+  local base = 3 + (i-1)*4
+  local maxHour = tonumber(ARGV[base+1])
+  local maxDay = tonumber(ARGV[base+2])
+  local hourStart = tonumber(ARGV[base+3])
+  local dayStart = tonumber(ARGV[base+4])
+
+  local stale = redis.call('ZRANGEBYSCORE', p, '-inf', now)
+  for _, m in ipairs(stale) do
+    redis.call('ZREM', w, m)
+  end
+  redis.call('ZREMRANGEBYSCORE', p, '-inf', now)
+  redis.call('ZREMRANGEBYSCORE', w, '-inf', '(' .. math.min(hourStart, dayStart))
+
+  local hc = redis.call('ZCOUNT', w, hourStart, '+inf')
+  local dc = redis.call('ZCOUNT', w, dayStart, '+inf')
+  if maxHour > 0 and hc >= maxHour then
+    local o = redis.call('ZRANGEBYSCORE', w, hourStart, '+inf', 'WITHSCORES', 'LIMIT', 0, 1)
+    return {i, 1, hc, dc, tonumber(o[2])}
+  end
+  if maxDay > 0 and dc >= maxDay then
+    local o = redis.call('ZRANGEBYSCORE', w, dayStart, '+inf', 'WITHSCORES', 'LIMIT', 0, 1)
+    return {i, 2, hc, dc, tonumber(o[2])}
+  end
+end
+
+for i = 1, n do
+  local w = KEYS[2*i-1]
+  local p = KEYS[2*i]
+  local base = 3 + (i-1)*4
+  local oldest = math.min(tonumber(ARGV[base+3]), tonumber(ARGV[base+4]))
+  -- :p must outlive every id in :w, otherwise a crashed reservation is never reclaimed
+  local ttl = math.max((now - oldest) + 3600000, pendingTTL * 2)
+  redis.call('ZADD', w, now, id)
+  redis.call('ZADD', p, now + pendingTTL, id)
+  redis.call('PEXPIRE', w, ttl)
+  redis.call('PEXPIRE', p, ttl)
+end
+return {0}
+`)
+
+// KEYS: (window, pending) per budget; ARGV: id, now
+// Returns the number of budgets where the reservation was already reclaimed
+// (committed after pendingTTL); the action is re-recorded there at now.
+var commitScript = redis.NewScript(`
+local missing = 0
+for i = 1, #KEYS, 2 do
+  local w = KEYS[i]
+  if redis.call('ZREM', KEYS[i+1], ARGV[1]) == 0 and not redis.call('ZSCORE', w, ARGV[1]) then
+    missing = missing + 1
+    redis.call('ZADD', w, tonumber(ARGV[2]), ARGV[1])
+    if redis.call('PTTL', w) < 0 then
+      redis.call('PEXPIRE', w, 25 * 3600000)
+    end
+  end
+end
+return missing
+`)
+
+// KEYS: (window, pending) per budget; ARGV: id
+var refundScript = redis.NewScript(`
+for i = 1, #KEYS do
+  redis.call('ZREM', KEYS[i], ARGV[1])
+end
+return 1
+`)
@@ -0,0 +1,138 @@
+package rate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/store"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Jeda default saat usage 100% tapi Meta tidak memberi estimasi pulih.
+const defaultUsagePause = 10 * time.Minute
+
+// UsageState: pemakaian kuota Meta terakhir untuk satu scope ("app", "acct:<id>").
+type UsageState struct {
+	Percent  int        `json:"percent"`
+	RegainAt *time.Time `json:"regainAt,omitempty"`
+	At       time.Time  `json:"at"`
+}
+
+// UsageVerdict: keputusan throttling dari usage terakhir.
+type UsageVerdict struct {
+	Scope      string
+	Percent    int
+	PauseUntil time.Time // non-zero: jangan panggil API sampai waktu ini
+	SlowDown   bool      // di atas ambang: tunda sebagian task
+}
+
+// UsageTracker menyimpan usage header Meta di Redis (dibagi antar worker)
+// dan memutuskan kapan worker harus melambat atau berhenti.
+type UsageTracker struct {
+	kv              *store.RedisStore
+	SlowdownPercent int
+}
+
+func NewUsageTracker(kv *store.RedisStore, slowdownPercent int) *UsageTracker {
+	return &UsageTracker{kv: kv, SlowdownPercent: slowdownPercent}
+}
+
+func AppUsageScope() string                        { return "app" }
+func AccountUsageScope(igBusinessID string) string { return "acct:" + igBusinessID }
+
+func usageKey(scope string) string { return "usage:" + scope }
+
+// Record menyimpan usage; regainMinutes > 0 dari estimated_time_to_regain_access.
+func (t *UsageTracker) Record(ctx context.Context, scope string, percent, regainMinutes int) error {
+	now := time.Now().UTC()
+	st := UsageState{Percent: percent, At: now}
+	ttl := time.Hour
+	if regainMinutes > 0 {
+		r := now.Add(time.Duration(regainMinutes) * time.Minute)
+		st.RegainAt = &r
+		if d := time.Until(r) + time.Minute; d > ttl {
+			ttl = d
+		}
+	}
+	b, _ := json.Marshal(st)
+	return t.kv.Set(ctx, usageKey(scope), string(b), ttl)
+}
+
+// Check mengembalikan verdict paling ketat dari semua scope.
+func (t *UsageTracker) Check(ctx context.Context, scopes ...string) (UsageVerdict, error) {
+	var out UsageVerdict
+	now := time.Now()
+	for _, scope := range scopes {
+		raw, err := t.kv.Get(ctx, usageKey(scope))
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return UsageVerdict{}, err
+		}
+		var st UsageState
+		if json.Unmarshal([]byte(raw), &st) != nil {
+			continue
+		}
+
+		var pause time.Time
+		if st.RegainAt != nil && st.RegainAt.After(now) {
+			pause = *st.RegainAt
+		} else if st.Percent >= 100 {
+			pause = st.At.Add(defaultUsagePause)
+		}
+		if pause.After(now) && pause.After(out.PauseUntil) {
+			out = UsageVerdict{Scope: scope, Percent: st.Percent, PauseUntil: pause}
+			continue
+		}
+		if out.PauseUntil.IsZero() && st.Percent > out.Percent {
+			out.Scope, out.Percent = scope, st.Percent
+			out.SlowDown = t.SlowdownPercent > 0 && st.Percent >= t.SlowdownPercent
+		}
+	}
+	return out, nil
+}
+
+// Proceed: saat SlowDown, hanya sebagian task yang lanjut; peluangnya turun
+// linear dari 100% di ambang ke 0% di 100% usage.
+func (t *UsageTracker) Proceed(v UsageVerdict) bool {
+	if !v.PauseUntil.IsZero() {
+		return false
+	}
+	if !v.SlowDown || t.SlowdownPercent >= 100 {
+		return true
+	}
+	p := float64(100-v.Percent) / float64(100-t.SlowdownPercent)
+	return rand.Float64() < p
+}
+
+// Observe mencatat usage dari satu response Graph API; dipakai sebagai
+// ig.Client.OnUsage.
+func (t *UsageTracker) Observe(u ig.Usage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if u.App != nil {
+		if err := t.Record(ctx, AppUsageScope(), u.App.Percent(), 0); err != nil {
+			log.Printf("[WARN] record app usage: %v", err)
+		}
+	}
+	for acct, entries := range u.Business {
+		pct, regain := 0, 0
+		for _, e := range entries {
+			if e.Percent() > pct {
+				pct = e.Percent()
+			}
+			if e.EstimatedTimeToRegainAccess > regain {
+				regain = e.EstimatedTimeToRegainAccess
+			}
+		}
+		if err := t.Record(ctx, AccountUsageScope(acct), pct, regain); err != nil {
+			log.Printf("[WARN] record usage acct=%s: %v", acct, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package rate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Warmup ramps an IG account's limits over its first Days after connecting,
+// starting at StartPercent of the full limit and growing linearly per day.
+type Warmup struct {
+	Days         int
+	StartPercent int
+}
+
+// Factor returns the fraction (0..1] of the full limit allowed at now for an
+// account whose ramp started at start.
+func (w Warmup) Factor(start, now time.Time) float64 {
+	if w.Days <= 0 || start.IsZero() {
+		return 1
+	}
+	if !now.After(start) {
+		return w.startFraction()
+	}
+	day := int(now.Sub(start) / (24 * time.Hour))
+	if day >= w.Days {
+		return 1
+	}
+	sf := w.startFraction()
+	return sf + (1-sf)*float64(day)/float64(w.Days)
+}
+
+// Apply scales l by the ramp factor. A positive limit never drops below 1.
+func (w Warmup) Apply(l LayerLimits, start, now time.Time) LayerLimits {
+	f := w.Factor(start, now)
+	if f >= 1 {
+		return l
+	}
+	return LayerLimits{MaxHour: scale(l.MaxHour, f), MaxDay: scale(l.MaxDay, f)}
+}
+
+func (w Warmup) startFraction() float64 {
+	p := w.StartPercent
+	if p <= 0 {
+		p = 10
+	}
+	if p > 100 {
+		p = 100
+	}
+	return float64(p) / 100
+}
+
+func scale(v int, f float64) int {
+	if v <= 0 {
+		return v
+	}
+	return int(math.Max(1, math.Floor(float64(v)*f)))
+}
+
+// WarmupOverride is set by operators per IG account. StartAt restarts the
+// ramp (e.g. after an action block); Disabled skips warm-up entirely.
+type WarmupOverride struct {
+	StartAt  *time.Time `json:"startAt,omitempty"`
+	Disabled bool       `json:"disabled"`
+}
+
+func warmupKey(igBusinessID string) string { return "warmup:override:" + igBusinessID }
+
+// WarmupOverride returns the operator override, or nil when none is set.
+func (l *Limiter) WarmupOverride(ctx context.Context, igBusinessID string) (*WarmupOverride, error) {
+	raw, err := l.kv.Get(ctx, warmupKey(igBusinessID))
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var o WarmupOverride
+	if err := json.Unmarshal([]byte(raw), &o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (l *Limiter) SetWarmupOverride(ctx context.Context, igBusinessID string, o WarmupOverride) error {
+	b, _ := json.Marshal(o)
+	return l.kv.Set(ctx, warmupKey(igBusinessID), string(b), 0)
+}
+
+// RestartWarmup starts the ramp again from now.
+func (l *Limiter) RestartWarmup(ctx context.Context, igBusinessID string) error {
+	now := time.Now().UTC()
+	return l.SetWarmupOverride(ctx, igBusinessID, WarmupOverride{StartAt: &now})
+}
+
+// WarmupStart resolves where the ramp starts: the override when present,
+// otherwise connectedAt. ok=false means warm-up does not apply.
+func (l *Limiter) WarmupStart(ctx context.Context, igBusinessID string, connectedAt time.Time) (start time.Time, ok bool, err error) {
+	o, err := l.WarmupOverride(ctx, igBusinessID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if o != nil {
+		if o.Disabled {
+			return time.Time{}, false, nil
+		}
+		if o.StartAt != nil {
+			return *o.StartAt, true, nil
+		}
+	}
+	if connectedAt.IsZero() {
+		return time.Time{}, false, nil
+	}
+	return connectedAt, true, nil
+}
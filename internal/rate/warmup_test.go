@@ -0,0 +1,25 @@
+package rate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWarmupRamp(t *testing.T) {
+	w := Warmup{Days: 10, StartPercent: 10}
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	full := LayerLimits{MaxHour: 50, MaxDay: 400}
+
+	day0 := w.Apply(full, start, start.Add(time.Hour))
+	if day0.MaxHour != 5 || day0.MaxDay != 40 {
+		t.Fatalf("day 0: got %+v", day0)
+	}
+	day5 := w.Apply(full, start, start.Add(5*24*time.Hour+time.Hour))
+	if day5.MaxHour != 27 || day5.MaxDay != 220 {
+		t.Fatalf("day 5: got %+v", day5)
+	}
+	done := w.Apply(full, start, start.Add(10*24*time.Hour))
+	if done != full {
+		t.Fatalf("after ramp: got %+v", done)
+	}
+}
@@ -3,19 +3,43 @@ package repo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"ig-webhook/internal/secret"
 	"ig-webhook/internal/store"
 	"time"
 )
 
+// Error resolusi token yang tidak akan pulih dengan retry; task sebaiknya
+// di-park sampai integrasi diperbaiki.
+var (
+	ErrTokenNotFound        = errors.New("ig token not found")
+	ErrTokenExpired         = errors.New("ig token expired")
+	ErrIntegrationSuspended = errors.New("integration not active")
+)
+
+// IsTokenUnavailable: err adalah salah satu error resolusi token di atas.
+func IsTokenUnavailable(err error) bool {
+	return errors.Is(err, ErrTokenNotFound) || errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrIntegrationSuspended)
+}
+
 type IGTokenLookup struct {
-	kv   *store.RedisStore
-	pool *pgxpool.Pool
+	kv     *store.RedisStore
+	pool   *pgxpool.Pool
+	cipher *secret.Envelope
 }
 
-func NewIGTokenLookup(kv *store.RedisStore, pool *pgxpool.Pool) *IGTokenLookup {
-	return &IGTokenLookup{kv: kv, pool: pool}
+// cipher nil = token disimpan/dibaca plaintext (dev).
+func NewIGTokenLookup(kv *store.RedisStore, pool *pgxpool.Pool, cipher *secret.Envelope) *IGTokenLookup {
+	return &IGTokenLookup{kv: kv, pool: pool, cipher: cipher}
+}
+
+// TokenCacheKey: cache token per IG business account.
+func TokenCacheKey(accountID string) string {
+	return "ig:token:" + accountID
 }
 
 type tokenCache struct {
@@ -23,14 +47,15 @@ type tokenCache struct {
 	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
 
+// Lookup token per integrasi (Integration.account_id = IG business account id).
 // 1) try to lookup in redis
 // 2) hit DB (Integration) to provider INSTAGRAM & aktif
 // 3) cache hasil dgn TTL aman (<= expiry - 2m) atau 30m kalau tidak ada expiry
-func (l *IGTokenLookup) Lookup(ctx context.Context, brandID string) (string, error) {
-	if brandID == "" {
-		return "", fmt.Errorf("brandID empty")
+func (l *IGTokenLookup) Lookup(ctx context.Context, accountID string) (string, error) {
+	if accountID == "" {
+		return "", fmt.Errorf("accountID empty")
 	}
-	cacheKey := fmt.Sprintf("ig:token:%s", brandID)
+	cacheKey := TokenCacheKey(accountID)
 
 	// 1) Cache
 	if raw, err := l.kv.Get(ctx, cacheKey); err == nil && raw != "" {
@@ -38,14 +63,17 @@ func (l *IGTokenLookup) Lookup(ctx context.Context, brandID string) (string, err
 		if json.Unmarshal([]byte(raw), &c) == nil && c.Token != "" {
 			// cek hampir kadaluarsa?
 			if c.ExpiresAt == nil || time.Until(*c.ExpiresAt) > 3*time.Minute {
-				return c.Token, nil
+				if token, err := openToken(ctx, l.cipher, c.Token); err == nil {
+					return token, nil
+				}
+				// cache tidak bisa dibuka (mis. KEK sudah dihapus) → baca ulang DB
 			}
 		}
 	}
 
 	// 2) DB
 	const q = `
-		SELECT access_token, expires_at
+		SELECT access_token, expires_at, status
 		FROM zosmed."integration"
 		WHERE account_id = $1
 		  AND type = 'INSTAGRAM'
@@ -53,17 +81,31 @@ func (l *IGTokenLookup) Lookup(ctx context.Context, brandID string) (string, err
 		LIMIT 1;
 	`
 	var (
-		token     string
+		token     *string
 		expiresAt *time.Time
+		status    string
 	)
-	if err := l.pool.QueryRow(ctx, q, brandID).Scan(&token, &expiresAt); err != nil {
+	if err := l.pool.QueryRow(ctx, q, accountID).Scan(&token, &expiresAt, &status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("%w: account=%s", ErrTokenNotFound, accountID)
+		}
 		return "", fmt.Errorf("lookup ig token db: %w", err)
 	}
-	if token == "" {
-		return "", fmt.Errorf("empty token for brand=%s", brandID)
+	if !IntegrationStatus(status).Usable() {
+		return "", fmt.Errorf("%w: account=%s status=%s", ErrIntegrationSuspended, accountID, status)
+	}
+	if token == nil || *token == "" {
+		return "", fmt.Errorf("%w: empty token for account=%s", ErrTokenNotFound, accountID)
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", fmt.Errorf("%w: account=%s expired_at=%s", ErrTokenExpired, accountID, expiresAt.UTC().Format(time.RFC3339))
+	}
+	plain, err := openToken(ctx, l.cipher, *token)
+	if err != nil {
+		return "", fmt.Errorf("decrypt ig token account=%s: %w", accountID, err)
 	}
 
-	// 3) Cache hasil
+	// 3) Cache hasil (terenkripsi)
 	ttl := 30 * time.Minute
 	if expiresAt != nil {
 		// simpan sedikit di bawah expiry supaya otomatis refresh
@@ -72,14 +114,17 @@ func (l *IGTokenLookup) Lookup(ctx context.Context, brandID string) (string, err
 			ttl = d
 		}
 	}
-	b, _ := json.Marshal(tokenCache{Token: token, ExpiresAt: expiresAt})
-	_ = l.kv.Set(ctx, cacheKey, string(b), ttl)
+	if sealed, err := sealToken(ctx, l.cipher, plain); err == nil {
+		b, _ := json.Marshal(tokenCache{Token: sealed, ExpiresAt: expiresAt})
+		_ = l.kv.Set(ctx, cacheKey, string(b), ttl)
+	}
 
-	return token, nil
+	return plain, nil
 }
 
 type IntegrationRepo struct {
-	Pool *pgxpool.Pool
+	Pool   *pgxpool.Pool
+	Cipher *secret.Envelope // nil = plaintext
 }
 
 type IntegrationRow struct {
@@ -89,7 +134,9 @@ type IntegrationRow struct {
 	ExpiresAt   *time.Time
 }
 
-func NewIntegrationRepo(p *pgxpool.Pool) *IntegrationRepo { return &IntegrationRepo{Pool: p} }
+func NewIntegrationRepo(p *pgxpool.Pool, cipher *secret.Envelope) *IntegrationRepo {
+	return &IntegrationRepo{Pool: p, Cipher: cipher}
+}
 
 func (r *IntegrationRepo) GetByIDForUser(ctx context.Context, id, userID string) (*IntegrationRow, error) {
 	const q = `
@@ -102,6 +149,11 @@ func (r *IntegrationRepo) GetByIDForUser(ctx context.Context, id, userID string)
 		Scan(&row.ID, &row.UserID, &row.AccessToken, &row.ExpiresAt); err != nil {
 		return nil, err
 	}
+	token, err := openToken(ctx, r.Cipher, row.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt token integration=%s: %w", id, err)
+	}
+	row.AccessToken = token
 	return &row, nil
 }
 
@@ -113,6 +165,296 @@ func (r *IntegrationRepo) UpdateToken(ctx context.Context, id string, newToken s
 			last_sync_at = NOW(),
 			updated_at   = NOW()
 		WHERE id = $1;`
-	_, err := r.Pool.Exec(ctx, u, id, newToken, expiresAt)
+	sealed, err := sealToken(ctx, r.Cipher, newToken)
+	if err != nil {
+		return fmt.Errorf("encrypt token integration=%s: %w", id, err)
+	}
+	_, err = r.Pool.Exec(ctx, u, id, sealed, expiresAt)
+	return err
+}
+
+// ReencryptBatch mengenkripsi ulang token (plaintext lama atau KEK non-aktif)
+// untuk maksimal limit baris setelah id `after`. Update memakai compare-and-swap
+// supaya token yang baru di-refresh tidak tertimpa. Return id terakhir yang
+// diperiksa ("" = selesai) dan account_id yang berubah (untuk invalidasi cache).
+func (r *IntegrationRepo) ReencryptBatch(ctx context.Context, after string, limit int) (string, []string, error) {
+	if r.Cipher == nil {
+		return "", nil, fmt.Errorf("token encryption not configured")
+	}
+	const q = `
+		SELECT id::text, COALESCE(account_id, ''), access_token
+		FROM zosmed."integration"
+		WHERE id::text > $1
+		  AND access_token IS NOT NULL AND access_token <> ''
+		ORDER BY id::text
+		LIMIT $2;`
+	rows, err := r.Pool.Query(ctx, q, after, limit)
+	if err != nil {
+		return "", nil, err
+	}
+	type item struct{ id, account, token string }
+	var items []item
+	for rows.Next() {
+		var it item
+		if err := rows.Scan(&it.id, &it.account, &it.token); err != nil {
+			rows.Close()
+			return "", nil, err
+		}
+		items = append(items, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+
+	const u = `
+		UPDATE zosmed."integration"
+		SET access_token = $3, updated_at = NOW()
+		WHERE id::text = $1 AND access_token = $2;`
+	var changed []string
+	for _, it := range items {
+		if !r.Cipher.NeedsRotation(it.token) {
+			continue
+		}
+		sealed, err := r.Cipher.Reencrypt(ctx, it.token)
+		if err != nil {
+			return "", changed, fmt.Errorf("reencrypt integration=%s: %w", it.id, err)
+		}
+		tag, err := r.Pool.Exec(ctx, u, it.id, it.token, sealed)
+		if err != nil {
+			return "", changed, err
+		}
+		if tag.RowsAffected() > 0 && it.account != "" {
+			changed = append(changed, it.account)
+		}
+	}
+	if len(items) < limit {
+		return "", changed, nil
+	}
+	return items[len(items)-1].id, changed, nil
+}
+
+// ConnectedAt mengembalikan waktu integrasi IG (account_id) pertama kali dibuat.
+func (r *IntegrationRepo) ConnectedAt(ctx context.Context, igBusinessID string) (time.Time, error) {
+	const q = `
+		SELECT created_at
+		FROM zosmed."integration"
+		WHERE account_id = $1
+		  AND type = 'INSTAGRAM'
+		ORDER BY updated_at DESC
+		LIMIT 1;`
+	var t time.Time
+	if err := r.Pool.QueryRow(ctx, q, igBusinessID).Scan(&t); err != nil {
+		return time.Time{}, err
+	}
+	return t, nil
+}
+
+// AccountTimezone mengembalikan timezone (IANA) akun IG; "" kalau belum diset.
+func (r *IntegrationRepo) AccountTimezone(ctx context.Context, igBusinessID string) (string, error) {
+	const q = `
+		SELECT COALESCE(timezone, '')
+		FROM zosmed."integration"
+		WHERE account_id = $1
+		  AND type = 'INSTAGRAM'
+		ORDER BY updated_at DESC
+		LIMIT 1;`
+	var tz string
+	err := r.Pool.QueryRow(ctx, q, igBusinessID).Scan(&tz)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return tz, err
+}
+
+func sealToken(ctx context.Context, c *secret.Envelope, token string) (string, error) {
+	if c == nil {
+		return token, nil
+	}
+	return c.Encrypt(ctx, token)
+}
+
+// openToken juga menerima plaintext lama (baris yang belum di-reencrypt).
+func openToken(ctx context.Context, c *secret.Envelope, v string) (string, error) {
+	if c == nil {
+		if secret.IsEncrypted(v) {
+			return "", fmt.Errorf("token is encrypted but no key provider configured")
+		}
+		return v, nil
+	}
+	return c.Decrypt(ctx, v)
+}
+
+// ExpiringIntegration: integrasi IG yang token-nya perlu di-refresh.
+type ExpiringIntegration struct {
+	ID        string
+	UserID    string
+	AccountID string
+	ExpiresAt time.Time
+}
+
+// ListExpiring mengembalikan integrasi INSTAGRAM yang token-nya kadaluarsa
+// dalam `within` (yang sudah kadaluarsa tidak bisa di-refresh, dilewati),
+// urut (expires_at, id). after = baris terakhir halaman sebelumnya (nil = awal).
+func (r *IntegrationRepo) ListExpiring(ctx context.Context, within time.Duration, after *ExpiringIntegration, limit int) ([]ExpiringIntegration, error) {
+	const q = `
+		SELECT id::text, user_id, COALESCE(account_id, ''), expires_at
+		FROM zosmed."integration"
+		WHERE type = 'INSTAGRAM'
+		  AND status = 'active'
+		  AND expires_at IS NOT NULL
+		  AND expires_at > NOW()
+		  AND expires_at < NOW() + $1::interval
+		  AND ($2::timestamptz IS NULL OR (expires_at, id::text) > ($2::timestamptz, $3::text))
+		ORDER BY expires_at, id::text
+		LIMIT $4;`
+	var afterAt *time.Time
+	var afterID string
+	if after != nil {
+		afterAt, afterID = &after.ExpiresAt, after.ID
+	}
+	rows, err := r.Pool.Query(ctx, q, fmt.Sprintf("%d seconds", int64(within.Seconds())), afterAt, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ExpiringIntegration
+	for rows.Next() {
+		var it ExpiringIntegration
+		if err := rows.Scan(&it.ID, &it.UserID, &it.AccountID, &it.ExpiresAt); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// InstagramConnection: hasil OAuth connect untuk disimpan sebagai integrasi.
+type InstagramConnection struct {
+	UserID      string
+	AccountID   string // IG business account id
+	AccessToken string
+	ExpiresAt   *time.Time
+}
+
+// ErrAccountOwned: akun IG sudah terhubung ke user lain dan belum di-revoke.
+var ErrAccountOwned = errors.New("instagram account connected by another user")
+
+// InstagramUpsert: hasil UpsertInstagram.
+type InstagramUpsert struct {
+	ID             string
+	PreviousStatus IntegrationStatus // kosong = integrasi baru
+	PreviousUserID string            // terisi kalau kepemilikan pindah dari user lain
+}
+
+// UpsertInstagram menyimpan integrasi INSTAGRAM untuk account_id (unik per
+// akun, migrasi 0003). Connect ulang oleh pemilik memperbarui token dan
+// mengaktifkan kembali statusnya. Akun milik user lain hanya bisa diambil
+// alih kalau integrasinya sudah revoked (deauthorize/data deletion); workflow
+// pemilik lama dinonaktifkan. Selain itu ErrAccountOwned.
+func (r *IntegrationRepo) UpsertInstagram(ctx context.Context, in InstagramConnection) (InstagramUpsert, error) {
+	var res InstagramUpsert
+	sealed, err := sealToken(ctx, r.Cipher, in.AccessToken)
+	if err != nil {
+		return res, fmt.Errorf("encrypt token account=%s: %w", in.AccountID, err)
+	}
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	const sel = `
+		SELECT user_id, status
+		FROM zosmed."integration"
+		WHERE account_id = $1 AND type = 'INSTAGRAM'
+		FOR UPDATE;`
+	var prevUser, prevStatus string
+	err = tx.QueryRow(ctx, sel, in.AccountID).Scan(&prevUser, &prevStatus)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return res, err
+	default:
+		if prevUser != in.UserID && IntegrationStatus(prevStatus) != StatusRevoked {
+			return res, fmt.Errorf("%w: account=%s", ErrAccountOwned, in.AccountID)
+		}
+		res.PreviousStatus = IntegrationStatus(prevStatus)
+		if prevUser != in.UserID {
+			res.PreviousUserID = prevUser
+		}
+	}
+
+	// ON CONFLICT menangani callback bersamaan untuk akun yang belum ada
+	const up = `
+		INSERT INTO zosmed."integration"
+			(id, user_id, account_id, type, access_token, expires_at, status, created_at, updated_at, last_sync_at)
+		VALUES ($1, $2, $3, 'INSTAGRAM', $4, $5, 'active', NOW(), NOW(), NOW())
+		ON CONFLICT (account_id, type) WHERE account_id IS NOT NULL AND account_id <> '' DO UPDATE
+		SET user_id           = EXCLUDED.user_id,
+			access_token      = EXCLUDED.access_token,
+			expires_at        = EXCLUDED.expires_at,
+			status            = 'active',
+			status_reason     = NULL,
+			status_changed_at = NOW(),
+			last_sync_at      = NOW(),
+			updated_at        = NOW()
+		WHERE zosmed."integration".user_id = EXCLUDED.user_id
+		   OR zosmed."integration".status = 'revoked'
+		RETURNING id::text;`
+	err = tx.QueryRow(ctx, up, uuid.NewString(), in.UserID, in.AccountID, sealed, in.ExpiresAt).Scan(&res.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// kalah balapan dengan connect user lain
+		return res, fmt.Errorf("%w: account=%s", ErrAccountOwned, in.AccountID)
+	}
+	if err != nil {
+		return res, err
+	}
+
+	if res.PreviousUserID != "" {
+		const deact = `UPDATE zosmed."workflow" SET is_active = FALSE WHERE integration_id::text = $1;`
+		if _, err := tx.Exec(ctx, deact, res.ID); err != nil {
+			return res, fmt.Errorf("deactivate previous owner workflows: %w", err)
+		}
+	}
+	return res, tx.Commit(ctx)
+}
+
+// ClearTokens menghapus token semua integrasi INSTAGRAM untuk account_id
+// (data deletion). Baris integrasi tetap ada karena direferensikan workflow.
+func (r *IntegrationRepo) ClearTokens(ctx context.Context, accountID string) error {
+	const u = `
+		UPDATE zosmed."integration"
+		SET access_token      = '',
+			expires_at        = NULL,
+			status            = 'revoked',
+			status_changed_at = NOW(),
+			updated_at        = NOW()
+		WHERE account_id = $1 AND type = 'INSTAGRAM';`
+	_, err := r.Pool.Exec(ctx, u, accountID)
 	return err
 }
+
+// ListActiveAccountIDs: account_id semua integrasi INSTAGRAM yang aktif.
+func (r *IntegrationRepo) ListActiveAccountIDs(ctx context.Context) ([]string, error) {
+	const q = `
+		SELECT DISTINCT account_id
+		FROM zosmed."integration"
+		WHERE type = 'INSTAGRAM'
+		  AND status = 'active'
+		  AND account_id IS NOT NULL AND account_id <> '';`
+	rows, err := r.Pool.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ig-webhook/internal/store"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IntegrationStatus: kesehatan integrasi IG (kolom integration.status).
+type IntegrationStatus string
+
+const (
+	StatusActive      IntegrationStatus = "active"
+	StatusNeedsReauth IntegrationStatus = "needs_reauth" // token dicabut/kadaluarsa, brand harus connect ulang
+	StatusSuspended   IntegrationStatus = "suspended"    // izin app dicabut / dihentikan operator
+	StatusRevoked     IntegrationStatus = "revoked"      // user mencabut app (deauthorize callback)
+)
+
+func (s IntegrationStatus) Valid() bool {
+	switch s {
+	case StatusActive, StatusNeedsReauth, StatusSuspended, StatusRevoked:
+		return true
+	}
+	return false
+}
+
+// Usable: hanya integrasi aktif yang boleh menjalankan aksi.
+func (s IntegrationStatus) Usable() bool { return s == StatusActive }
+
+const statusCacheTTL = time.Minute
+
+// IntegrationStatusLookup membaca/menyetel status integrasi per IG business
+// account (account_id), dengan cache Redis singkat.
+type IntegrationStatusLookup struct {
+	kv   *store.RedisStore
+	pool *pgxpool.Pool
+}
+
+func NewIntegrationStatusLookup(kv *store.RedisStore, pool *pgxpool.Pool) *IntegrationStatusLookup {
+	return &IntegrationStatusLookup{kv: kv, pool: pool}
+}
+
+func statusCacheKey(accountID string) string { return "ig:status:" + accountID }
+
+// Status integrasi terbaru untuk account_id. Akun tanpa baris integrasi
+// dianggap aktif (resolusi token yang akan gagal).
+func (l *IntegrationStatusLookup) Status(ctx context.Context, accountID string) (IntegrationStatus, error) {
+	if raw, err := l.kv.Get(ctx, statusCacheKey(accountID)); err == nil && raw != "" {
+		return IntegrationStatus(raw), nil
+	}
+	const q = `
+		SELECT status
+		FROM zosmed."integration"
+		WHERE account_id = $1
+		  AND type = 'INSTAGRAM'
+		ORDER BY updated_at DESC
+		LIMIT 1;`
+	var s string
+	if err := l.pool.QueryRow(ctx, q, accountID).Scan(&s); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return StatusActive, nil
+		}
+		return "", fmt.Errorf("lookup integration status: %w", err)
+	}
+	_ = l.kv.Set(ctx, statusCacheKey(accountID), s, statusCacheTTL)
+	return IntegrationStatus(s), nil
+}
+
+// SetStatus menyimpan status untuk semua integrasi INSTAGRAM dengan
+// account_id ini. changed=false kalau status sudah sama (tidak perlu event).
+// Status revoked hanya bisa diganti ke active (connect ulang).
+func (l *IntegrationStatusLookup) SetStatus(ctx context.Context, accountID string, s IntegrationStatus, reason string) (bool, error) {
+	if !s.Valid() {
+		return false, fmt.Errorf("invalid integration status %q", s)
+	}
+	const u = `
+		UPDATE zosmed."integration"
+		SET status            = $2,
+			status_reason     = NULLIF($3, ''),
+			status_changed_at = NOW(),
+			updated_at        = NOW()
+		WHERE account_id = $1
+		  AND type = 'INSTAGRAM'
+		  AND status <> $2
+		  AND (status <> 'revoked' OR $2 = 'active');`
+	tag, err := l.pool.Exec(ctx, u, accountID, string(s), reason)
+	if err != nil {
+		return false, err
+	}
+	_ = l.kv.Del(ctx, statusCacheKey(accountID), TokenCacheKey(accountID))
+	return tag.RowsAffected() > 0, nil
+}
+
+// Invalidate menghapus cache status & token akun (mis. setelah connect ulang).
+func (l *IntegrationStatusLookup) Invalidate(ctx context.Context, accountID string) error {
+	return l.kv.Del(ctx, statusCacheKey(accountID), TokenCacheKey(accountID))
+}
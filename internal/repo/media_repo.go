@@ -0,0 +1,152 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MediaRow: satu media di katalog zosmed."ig_media".
+type MediaRow struct {
+	ID               string     `json:"id"`
+	AccountID        string     `json:"accountId"`
+	Permalink        string     `json:"permalink"`
+	Shortcode        string     `json:"shortcode"`
+	Caption          string     `json:"caption"`
+	MediaType        string     `json:"mediaType"`
+	MediaProductType string     `json:"mediaProductType"`
+	Timestamp        *time.Time `json:"timestamp,omitempty"`
+}
+
+type MediaRepo struct {
+	Pool *pgxpool.Pool
+}
+
+func NewMediaRepo(p *pgxpool.Pool) *MediaRepo { return &MediaRepo{Pool: p} }
+
+// Upsert menyimpan media; return jumlah media yang sebelumnya belum ada.
+func (r *MediaRepo) Upsert(ctx context.Context, rows []MediaRow) (int, error) {
+	const q = `
+		INSERT INTO zosmed."ig_media"
+			(id, account_id, permalink, shortcode, caption, media_type, media_product_type, "timestamp", synced_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			permalink          = EXCLUDED.permalink,
+			shortcode          = EXCLUDED.shortcode,
+			caption            = EXCLUDED.caption,
+			media_type         = EXCLUDED.media_type,
+			media_product_type = EXCLUDED.media_product_type,
+			"timestamp"        = EXCLUDED."timestamp",
+			synced_at          = NOW()
+		RETURNING (xmax = 0) AS inserted;`
+	added := 0
+	for _, m := range rows {
+		var inserted bool
+		if err := r.Pool.QueryRow(ctx, q, m.ID, m.AccountID, m.Permalink, m.Shortcode, m.Caption,
+			m.MediaType, m.MediaProductType, m.Timestamp).Scan(&inserted); err != nil {
+			return added, fmt.Errorf("upsert media %s: %w", m.ID, err)
+		}
+		if inserted {
+			added++
+		}
+	}
+	return added, nil
+}
+
+const mediaColumns = `id, account_id, COALESCE(permalink, ''), COALESCE(shortcode, ''), COALESCE(caption, ''),
+	COALESCE(media_type, ''), COALESCE(media_product_type, ''), "timestamp"`
+
+func scanMedia(row pgx.Row) (*MediaRow, error) {
+	var m MediaRow
+	if err := row.Scan(&m.ID, &m.AccountID, &m.Permalink, &m.Shortcode, &m.Caption,
+		&m.MediaType, &m.MediaProductType, &m.Timestamp); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Get media by id; nil kalau belum ada di katalog.
+func (r *MediaRepo) Get(ctx context.Context, mediaID string) (*MediaRow, error) {
+	m, err := scanMedia(r.Pool.QueryRow(ctx, `SELECT `+mediaColumns+` FROM zosmed."ig_media" WHERE id = $1`, mediaID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return m, err
+}
+
+// IDByShortcode; "" kalau belum ada di katalog.
+func (r *MediaRepo) IDByShortcode(ctx context.Context, accountID, shortcode string) (string, error) {
+	const q = `
+		SELECT id FROM zosmed."ig_media"
+		WHERE account_id = $1 AND shortcode = $2
+		LIMIT 1;`
+	var id string
+	err := r.Pool.QueryRow(ctx, q, accountID, shortcode).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return id, err
+}
+
+// FirstAfter: media akun yang paling awal terbit setelah t; nil kalau belum ada.
+func (r *MediaRepo) FirstAfter(ctx context.Context, accountID string, t time.Time) (*MediaRow, error) {
+	q := `SELECT ` + mediaColumns + ` FROM zosmed."ig_media"
+		WHERE account_id = $1 AND "timestamp" > $2
+		ORDER BY "timestamp" ASC, id ASC
+		LIMIT 1;`
+	m, err := scanMedia(r.Pool.QueryRow(ctx, q, accountID, t))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return m, err
+}
+
+// Recent: media akun terbaru (urut waktu terbit menurun), maksimal limit.
+func (r *MediaRepo) Recent(ctx context.Context, accountID string, limit int) ([]MediaRow, error) {
+	q := `SELECT ` + mediaColumns + ` FROM zosmed."ig_media"
+		WHERE account_id = $1 AND "timestamp" IS NOT NULL
+		ORDER BY "timestamp" DESC, id DESC
+		LIMIT $2;`
+	rows, err := r.Pool.Query(ctx, q, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []MediaRow
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *m)
+	}
+	return out, rows.Err()
+}
+
+// IDs: semua media id akun di katalog.
+func (r *MediaRepo) IDs(ctx context.Context, accountID string) ([]string, error) {
+	rows, err := r.Pool.Query(ctx, `SELECT id FROM zosmed."ig_media" WHERE account_id = $1`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// DeleteAccount menghapus katalog media akun (data deletion).
+func (r *MediaRepo) DeleteAccount(ctx context.Context, accountID string) error {
+	_, err := r.Pool.Exec(ctx, `DELETE FROM zosmed."ig_media" WHERE account_id = $1`, accountID)
+	return err
+}
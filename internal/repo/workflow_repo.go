@@ -76,3 +76,27 @@ func (r *PGWorkflowRepo) ListActiveWorkflowsForIGAccount(igBusinessID string) ([
 	}
 	return out, nil
 }
+
+// ListWorkflowIDsForIGAccount mengembalikan semua workflow (aktif maupun tidak)
+// milik integrasi dengan account_id ini.
+func (r *PGWorkflowRepo) ListWorkflowIDsForIGAccount(ctx context.Context, igBusinessID string) ([]string, error) {
+	const q = `
+		SELECT w.id::text
+		FROM zosmed."workflow" AS w
+		JOIN zosmed."integration" AS i ON i.id = w.integration_id
+		WHERE i.account_id = $1;`
+	rows, err := r.pool.Query(ctx, q, igBusinessID)
+	if err != nil {
+		return nil, fmt.Errorf("query workflow ids: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,142 @@
+package secret
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Format ciphertext: "enc:v1:<keyID>:<wrapped DEK b64>:<nonce|ciphertext b64>".
+// Nilai tanpa prefix dianggap plaintext lama (belum dienkripsi).
+const prefix = "enc:v1:"
+
+var ErrMalformed = errors.New("malformed envelope")
+
+// KeyProvider membungkus/membuka data key (DEK) dengan key-encryption key (KEK).
+// Implementasi produksi bisa memakai KMS; LocalKeyProvider untuk dev/test.
+type KeyProvider interface {
+	ActiveKeyID() string
+	WrapKey(ctx context.Context, keyID string, dek []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Envelope: enkripsi AES-256-GCM dengan DEK acak per nilai, DEK dibungkus KEK.
+type Envelope struct {
+	kp KeyProvider
+}
+
+func NewEnvelope(kp KeyProvider) *Envelope {
+	return &Envelope{kp: kp}
+}
+
+func IsEncrypted(v string) bool {
+	return strings.HasPrefix(v, prefix)
+}
+
+// KeyID mengembalikan KEK yang dipakai nilai terenkripsi ("" kalau plaintext).
+func KeyID(v string) string {
+	if !IsEncrypted(v) {
+		return ""
+	}
+	parts := strings.SplitN(strings.TrimPrefix(v, prefix), ":", 2)
+	return parts[0]
+}
+
+// NeedsRotation: plaintext atau dienkripsi dengan KEK non-aktif.
+func (e *Envelope) NeedsRotation(v string) bool {
+	return v != "" && KeyID(v) != e.kp.ActiveKeyID()
+}
+
+func (e *Envelope) Encrypt(ctx context.Context, plaintext string) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	sealed, err := seal(dek, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	keyID := e.kp.ActiveKeyID()
+	wrapped, err := e.kp.WrapKey(ctx, keyID, dek)
+	if err != nil {
+		return "", fmt.Errorf("wrap key: %w", err)
+	}
+	enc := base64.RawStdEncoding
+	return prefix + keyID + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(sealed), nil
+}
+
+// Decrypt membuka nilai terenkripsi; plaintext lama dikembalikan apa adanya.
+func (e *Envelope) Decrypt(ctx context.Context, v string) (string, error) {
+	if !IsEncrypted(v) {
+		return v, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(v, prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+	enc := base64.RawStdEncoding
+	wrapped, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	sealed, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	dek, err := e.kp.UnwrapKey(ctx, parts[0], wrapped)
+	if err != nil {
+		return "", fmt.Errorf("unwrap key %s: %w", parts[0], err)
+	}
+	pt, err := open(dek, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+// Reencrypt membuka lalu mengenkripsi ulang dengan KEK aktif.
+func (e *Envelope) Reencrypt(ctx context.Context, v string) (string, error) {
+	pt, err := e.Decrypt(ctx, v)
+	if err != nil {
+		return "", err
+	}
+	return e.Encrypt(ctx, pt)
+}
+
+// seal: nonce || AES-GCM(key, plaintext)
+func seal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ct := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ct, nil)
+}
@@ -0,0 +1,145 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testProvider(t *testing.T, active string) *LocalKeyProvider {
+	t.Helper()
+	kp, err := NewLocalKeyProvider(active, map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	})
+	if err != nil {
+		t.Fatalf("provider: %v", err)
+	}
+	return kp
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	env := NewEnvelope(testProvider(t, "k1"))
+
+	ct, err := env.Encrypt(ctx, "IGQVJ-secret-token")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !IsEncrypted(ct) || KeyID(ct) != "k1" {
+		t.Fatalf("unexpected ciphertext %q", ct)
+	}
+	pt, err := env.Decrypt(ctx, ct)
+	if err != nil || pt != "IGQVJ-secret-token" {
+		t.Fatalf("decrypt: %q, %v", pt, err)
+	}
+
+	// plaintext lama tetap terbaca
+	if pt, err := env.Decrypt(ctx, "legacy-token"); err != nil || pt != "legacy-token" {
+		t.Fatalf("plaintext passthrough: %q, %v", pt, err)
+	}
+}
+
+func TestEnvelopeRotation(t *testing.T) {
+	ctx := context.Background()
+	old := NewEnvelope(testProvider(t, "k1"))
+	ct, err := old.Encrypt(ctx, "tok")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	rotated := NewEnvelope(testProvider(t, "k2"))
+	if !rotated.NeedsRotation(ct) || !rotated.NeedsRotation("plain") {
+		t.Fatalf("expected old-key and plaintext values to need rotation")
+	}
+	ct2, err := rotated.Reencrypt(ctx, ct)
+	if err != nil {
+		t.Fatalf("reencrypt: %v", err)
+	}
+	if KeyID(ct2) != "k2" || rotated.NeedsRotation(ct2) {
+		t.Fatalf("expected value under k2, got %q", ct2)
+	}
+	if pt, err := rotated.Decrypt(ctx, ct2); err != nil || pt != "tok" {
+		t.Fatalf("decrypt rotated: %q, %v", pt, err)
+	}
+}
+
+// fakeVault meniru endpoint transit encrypt/decrypt/keys (ciphertext =
+// plaintext berprefix versi). *version = latest_version key.
+func fakeVault(t *testing.T, version *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "tok" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		var in map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		switch r.URL.Path {
+		case "/v1/transit/keys/tokens":
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]int{"latest_version": *version}})
+		case "/v1/transit/encrypt/tokens":
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"ciphertext": fmt.Sprintf("vault:v%d:%s", *version, in["plaintext"])}})
+		case "/v1/transit/decrypt/tokens":
+			_, pt, _ := strings.Cut(strings.TrimPrefix(in["ciphertext"], "vault:v"), ":")
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"plaintext": pt}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVaultTransitProvider(t *testing.T) {
+	ctx := context.Background()
+	version := 1
+	srv := fakeVault(t, &version)
+	kp, err := NewKeyProvider(ProviderConfig{Kind: ProviderVault, VaultAddr: srv.URL, VaultToken: "tok", VaultKey: "tokens"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := NewEnvelope(kp)
+	ct, err := env.Encrypt(ctx, "IGQVJ-secret-token")
+	if err != nil || KeyID(ct) != "tokens/v1" {
+		t.Fatalf("encrypt: %q, %v", ct, err)
+	}
+	if pt, err := env.Decrypt(ctx, ct); err != nil || pt != "IGQVJ-secret-token" {
+		t.Fatalf("decrypt: %q, %v", pt, err)
+	}
+	if env.NeedsRotation(ct) {
+		t.Fatal("current key version marked for rotation")
+	}
+
+	// rotasi key di Vault: token versi lama perlu di-wrap ulang
+	version = 2
+	kp.(*VaultTransitProvider).checkedAt = time.Time{}
+	if !env.NeedsRotation(ct) {
+		t.Fatal("rotated key version not detected")
+	}
+	ct2, err := env.Reencrypt(ctx, ct)
+	if err != nil || KeyID(ct2) != "tokens/v2" || env.NeedsRotation(ct2) {
+		t.Fatalf("reencrypt: %q, %v", ct2, err)
+	}
+	// token lama tanpa versi (sebelum key ID berversi) tetap bisa dibuka
+	legacy := strings.Replace(ct, "tokens/v1", "tokens", 1)
+	if pt, err := env.Decrypt(ctx, legacy); err != nil || pt != "IGQVJ-secret-token" || !env.NeedsRotation(legacy) {
+		t.Fatalf("legacy key id: %q, %v", pt, err)
+	}
+
+	bad, _ := NewVaultTransitProvider(srv.URL, "wrong", "", "tokens")
+	if _, err := NewEnvelope(bad).Encrypt(ctx, "x"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected vault error, got %v", err)
+	}
+	if _, err := NewKeyProvider(ProviderConfig{Kind: "kms"}); err == nil {
+		t.Fatal("unknown provider accepted")
+	}
+}
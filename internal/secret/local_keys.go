@@ -0,0 +1,74 @@
+package secret
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LocalKeyProvider menyimpan KEK di file lokal (dev/test).
+//
+// Format keyfile:
+//
+//	{"active": "k2", "keys": {"k1": "<base64 32 byte>", "k2": "<base64 32 byte>"}}
+//
+// Rotasi: tambah key baru, ganti "active", jalankan re-encrypt, lalu hapus key lama.
+type LocalKeyProvider struct {
+	active string
+	keys   map[string][]byte
+}
+
+func NewLocalKeyProvider(active string, keys map[string][]byte) (*LocalKeyProvider, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q not found", active)
+	}
+	for id, k := range keys {
+		if len(k) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes, got %d", id, len(k))
+		}
+	}
+	return &LocalKeyProvider{active: active, keys: keys}, nil
+}
+
+func LoadLocalKeyFile(path string) (*LocalKeyProvider, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f struct {
+		Active string            `json:"active"`
+		Keys   map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("parse keyfile: %w", err)
+	}
+	keys := make(map[string][]byte, len(f.Keys))
+	for id, v := range f.Keys {
+		k, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		keys[id] = k
+	}
+	return NewLocalKeyProvider(f.Active, keys)
+}
+
+func (p *LocalKeyProvider) ActiveKeyID() string { return p.active }
+
+func (p *LocalKeyProvider) WrapKey(_ context.Context, keyID string, dek []byte) ([]byte, error) {
+	kek, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+	return seal(kek, dek)
+}
+
+func (p *LocalKeyProvider) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	kek, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+	return open(kek, wrapped)
+}
@@ -0,0 +1,193 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultTransitProvider membungkus DEK dengan Vault Transit (KEK tidak pernah
+// keluar dari Vault). Key ID envelope = "<transit key>/v<versi>": setelah key
+// di-rotate di Vault, token dengan versi lama terdeteksi NeedsRotation dan
+// di-wrap ulang oleh job re-encrypt. Token Vault butuh izin read
+// <mount>/keys/<key> untuk membaca versi terbaru.
+type VaultTransitProvider struct {
+	Addr  string // mis. https://vault.internal:8200
+	Token string
+	Mount string // default "transit"
+	Key   string
+
+	HTTP *http.Client
+
+	mu        sync.Mutex
+	version   int // latest_version terakhir yang diketahui
+	checkedAt time.Time
+}
+
+// Versi key dibaca ulang dari Vault paling sering sekali per interval ini.
+const vaultKeyVersionTTL = 5 * time.Minute
+
+func NewVaultTransitProvider(addr, token, mount, key string) (*VaultTransitProvider, error) {
+	if addr == "" || token == "" || key == "" {
+		return nil, fmt.Errorf("vault transit: addr, token and key are required")
+	}
+	if strings.ContainsAny(key, ":/") {
+		return nil, fmt.Errorf("vault transit: key name %q must not contain ':' or '/'", key)
+	}
+	if mount == "" {
+		mount = "transit"
+	}
+	return &VaultTransitProvider{
+		Addr:  strings.TrimRight(addr, "/"),
+		Token: token,
+		Mount: strings.Trim(mount, "/"),
+		Key:   key,
+		HTTP:  &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+// ActiveKeyID: "<key>/v<latest_version>"; kalau versi belum bisa dibaca dari
+// Vault, nama key saja (token tetap terenkripsi, ditandai rotasi nanti).
+func (p *VaultTransitProvider) ActiveKeyID() string {
+	if v := p.latestVersion(); v > 0 {
+		return fmt.Sprintf("%s/v%d", p.Key, v)
+	}
+	return p.Key
+}
+
+// latestVersion membaca latest_version key dari Vault (cache vaultKeyVersionTTL).
+func (p *VaultTransitProvider) latestVersion() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.version > 0 && time.Since(p.checkedAt) < vaultKeyVersionTTL {
+		return p.version
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var out struct {
+		LatestVersion int `json:"latest_version"`
+	}
+	if err := p.call(ctx, http.MethodGet, "keys", p.Key, nil, &out); err != nil {
+		log.Printf("[WARN] vault transit: read key version: %v", err)
+		return p.version
+	}
+	p.version, p.checkedAt = out.LatestVersion, time.Now()
+	return p.version
+}
+
+// observeVersion mencatat versi dari ciphertext "vault:vN:..." hasil encrypt,
+// supaya rotasi terdeteksi tanpa menunggu cache kadaluarsa.
+func (p *VaultTransitProvider) observeVersion(ciphertext string) {
+	rest, ok := strings.CutPrefix(ciphertext, "vault:v")
+	if !ok {
+		return
+	}
+	num, _, _ := strings.Cut(rest, ":")
+	v, err := strconv.Atoi(num)
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	if v > p.version {
+		p.version = v
+	}
+	p.mu.Unlock()
+}
+
+// transitKey: nama key dari key ID envelope ("<key>/vN" atau "<key>" lama).
+func transitKey(keyID string) string {
+	name, _, _ := strings.Cut(keyID, "/")
+	return name
+}
+
+func (p *VaultTransitProvider) WrapKey(ctx context.Context, keyID string, dek []byte) ([]byte, error) {
+	var out struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := p.call(ctx, http.MethodPost, "encrypt", transitKey(keyID), map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dek)}, &out); err != nil {
+		return nil, err
+	}
+	p.observeVersion(out.Ciphertext)
+	return []byte(out.Ciphertext), nil
+}
+
+func (p *VaultTransitProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := p.call(ctx, http.MethodPost, "decrypt", transitKey(keyID), map[string]string{"ciphertext": string(wrapped)}, &out); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Plaintext)
+}
+
+func (p *VaultTransitProvider) call(ctx context.Context, method, op, key string, in map[string]string, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, _ := json.Marshal(in)
+		body = bytes.NewReader(b)
+	}
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", p.Addr, p.Mount, op, key)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault transit %s: %w", op, err)
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("vault transit %s: status %d: %w", op, resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("vault transit %s: status %d: %s", op, resp.StatusCode, strings.Join(res.Errors, "; "))
+	}
+	return json.Unmarshal(res.Data, out)
+}
+
+// Provider yang didukung TOKEN_KEY_PROVIDER.
+const (
+	ProviderNone  = ""      // plaintext (hanya dev)
+	ProviderLocal = "local" // keyfile lokal (dev/test)
+	ProviderVault = "vault" // Vault Transit (produksi)
+)
+
+// ProviderConfig: pilihan KeyProvider dari konfigurasi.
+type ProviderConfig struct {
+	Kind       string
+	KeyFile    string
+	VaultAddr  string
+	VaultToken string
+	VaultMount string
+	VaultKey   string
+}
+
+// NewKeyProvider membuat KeyProvider sesuai Kind; nil untuk ProviderNone.
+func NewKeyProvider(c ProviderConfig) (KeyProvider, error) {
+	switch c.Kind {
+	case ProviderNone:
+		return nil, nil
+	case ProviderLocal:
+		return LoadLocalKeyFile(c.KeyFile)
+	case ProviderVault:
+		return NewVaultTransitProvider(c.VaultAddr, c.VaultToken, c.VaultMount, c.VaultKey)
+	default:
+		return nil, fmt.Errorf("unknown token key provider %q", c.Kind)
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/store"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const commentCacheTTL = 24 * time.Hour
+
+// CommentLookup mengambil komentar (parent thread) dari Graph API dengan cache Redis.
+type CommentLookup struct {
+	Tokens    TokenResolver
+	KV        *store.RedisStore
+	NewClient func(token string) *ig.Client
+}
+
+func NewCommentLookup(tokens TokenResolver, kv *store.RedisStore) *CommentLookup {
+	return &CommentLookup{Tokens: tokens, KV: kv, NewClient: ig.NewClient}
+}
+
+func commentCacheKey(acct, commentID string) string { return "ig:comment:" + acct + ":" + commentID }
+
+func (l *CommentLookup) Comment(ctx context.Context, acct, commentID string) (*ig.Comment, error) {
+	key := commentCacheKey(acct, commentID)
+	if v, err := l.KV.Get(ctx, key); err == nil {
+		var c ig.Comment
+		if json.Unmarshal([]byte(v), &c) == nil {
+			return &c, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		log.Printf("[WARN] comment cache: %v", err)
+	}
+
+	token, err := l.Tokens.Lookup(ctx, acct)
+	if err != nil {
+		return nil, err
+	}
+	c, err := l.NewClient(token).GetComment(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if b, err := json.Marshal(c); err == nil {
+		_ = l.KV.Set(ctx, key, string(b), commentCacheTTL)
+	}
+	return c, nil
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/processor"
+	"ig-webhook/internal/rate"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/store"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	reconcileAcctLockTTL = 10 * time.Minute
+	commentsPageSize     = 50
+	// media terbaru yang dicek untuk workflow mode all/caption/next_post
+	reconcileRecentMedia = 10
+)
+
+// CommentReconciler menarik komentar terbaru dari post yang dipantau workflow
+// aktif dan memproses komentar yang terlewat webhook (belum ada idem:event).
+type CommentReconciler struct {
+	KV           *store.RedisStore
+	Tokens       TokenResolver
+	Integrations *repo.IntegrationRepo
+	Workflows    repo.WorkflowRepo
+	Processor    *processor.CommentProcessor
+	Usage        *rate.UsageTracker // nil = tidak cek kuota Meta
+	Media        *MediaCatalog      // resolve permalink/shortcode (nil = hanya media id)
+
+	Lookback       time.Duration // komentar lebih lama dari ini diabaikan
+	MaxCallsPerRun int           // budget panggilan Graph API per akun per run
+
+	NewClient func(token string) *ig.Client
+}
+
+func NewCommentReconciler(kv *store.RedisStore, tokens TokenResolver, integrations *repo.IntegrationRepo, workflows repo.WorkflowRepo, proc *processor.CommentProcessor) *CommentReconciler {
+	return &CommentReconciler{
+		KV:             kv,
+		Tokens:         tokens,
+		Integrations:   integrations,
+		Workflows:      workflows,
+		Processor:      proc,
+		Lookback:       2 * time.Hour,
+		MaxCallsPerRun: 20,
+		NewClient:      ig.NewClient,
+	}
+}
+
+func (r *CommentReconciler) Run(ctx context.Context) {
+	accts, err := r.Integrations.ListActiveAccountIDs(ctx)
+	if err != nil {
+		log.Printf("[ERR] comment reconcile: list accounts: %v", err)
+		return
+	}
+	for _, acct := range accts {
+		if ctx.Err() != nil {
+			return
+		}
+		r.reconcileAccount(ctx, acct)
+	}
+}
+
+func (r *CommentReconciler) reconcileAccount(ctx context.Context, acct string) {
+	lockKey := "lock:reconcile:comments:" + acct
+	lockToken := uuid.NewString()
+	got, err := r.KV.SetNX(ctx, lockKey, lockToken, reconcileAcctLockTTL)
+	if err != nil || !got {
+		return
+	}
+	// lepas hanya kalau lock masih milik run ini (bisa sudah kadaluarsa)
+	defer func() { _, _ = r.KV.DelIfEqual(context.Background(), lockKey, lockToken) }()
+
+	// kuota Meta hampir habis: webhook & aksi lebih penting dari rekonsiliasi
+	if r.Usage != nil {
+		v, err := r.Usage.Check(ctx, rate.AppUsageScope(), rate.AccountUsageScope(acct))
+		if err == nil && (!v.PauseUntil.IsZero() || v.SlowDown) {
+			log.Printf("[RECONCILE] skip acct=%s usage=%d%% scope=%s", acct, v.Percent, v.Scope)
+			return
+		}
+	}
+
+	wfs, err := r.Workflows.ListActiveWorkflowsForIGAccount(acct)
+	if err != nil {
+		log.Printf("[ERR] comment reconcile acct=%s: workflows: %v", acct, err)
+		return
+	}
+	posts := processor.TargetedPostIDs(wfs)
+	if r.Media != nil {
+		var errs map[string]error
+		posts, errs = r.Media.ResolveAll(ctx, acct, posts)
+		for ref, err := range errs {
+			log.Printf("[WARN] comment reconcile acct=%s ref=%q: %v", acct, ref, err)
+		}
+		// mode tanpa daftar post: kandidat dari katalog media
+		recent, err := r.Media.RecentMedia(ctx, acct, reconcileRecentMedia)
+		if err != nil {
+			log.Printf("[WARN] comment reconcile acct=%s: recent media: %v", acct, err)
+		}
+		posts = appendUnique(posts, r.Processor.CatalogTargetedPostIDs(ctx, acct, wfs, recent)...)
+	}
+	if len(posts) == 0 {
+		return
+	}
+	token, err := r.Tokens.Lookup(ctx, acct)
+	if err != nil {
+		log.Printf("[WARN] comment reconcile acct=%s: %v", acct, err)
+		return
+	}
+	client := r.NewClient(token)
+	if r.Usage != nil {
+		client.OnUsage = r.Usage.Observe
+	}
+
+	since := time.Now().Add(-r.Lookback)
+	budget := r.MaxCallsPerRun
+	recovered := 0
+	for _, mediaID := range posts {
+		n, used, err := r.reconcileMedia(ctx, client, acct, mediaID, since, budget)
+		recovered += n
+		budget -= used
+		if err != nil {
+			log.Printf("[ERR] comment reconcile acct=%s media=%s: %v", acct, mediaID, err)
+			if ig.Classify(err) != ig.ClassRetryable {
+				break // token/izin/kuota bermasalah: berhenti untuk akun ini
+			}
+		}
+		if budget <= 0 {
+			log.Printf("[RECONCILE] api budget exhausted acct=%s", acct)
+			break
+		}
+	}
+	if recovered > 0 {
+		log.Printf("[RECONCILE] acct=%s recovered=%d missed comments", acct, recovered)
+	}
+}
+
+// reconcileMedia menelusuri komentar media sampai melewati `since` atau
+// budget habis. Return jumlah komentar yang diproses ulang & panggilan API.
+func (r *CommentReconciler) reconcileMedia(ctx context.Context, client *ig.Client, acct, mediaID string, since time.Time, budget int) (int, int, error) {
+	recovered, calls := 0, 0
+	after := ""
+	for calls < budget {
+		page, err := client.ListComments(ctx, mediaID, after, commentsPageSize)
+		calls++
+		if err != nil {
+			return recovered, calls, err
+		}
+		reachedOld := false
+		for _, cm := range page.Comments {
+			if cm.Timestamp.Before(since) {
+				// balasan lama tidak berarti komentar utama berikutnya juga lama
+				if cm.ParentID == "" {
+					reachedOld = true
+				}
+				continue
+			}
+			seen, err := r.KV.Exists(ctx, "idem:event:"+cm.ID)
+			if err != nil {
+				return recovered, calls, err
+			}
+			if seen {
+				continue
+			}
+			from := cm.From
+			if from.Username == "" {
+				from.Username = cm.Username
+			}
+			ev := processor.CommentEvent{
+				EventID:         cm.ID,
+				BrandID:         processor.BrandIDForAccount(acct),
+				IGBusinessID:    acct,
+				CommentID:       cm.ID,
+				PostID:          mediaID,
+				ParentCommentID: cm.ParentID,
+				Text:            cm.Text,
+				FromIGUserID:    from.ID,
+				FromUsername:    from.Username,
+				CommentedAt:     cm.Timestamp.UTC(),
+				Reconciled:      true,
+			}
+			if err := r.Processor.Process(ctx, ev); err != nil {
+				return recovered, calls, err
+			}
+			recovered++
+		}
+		if reachedOld || page.After == "" {
+			break
+		}
+		after = page.After
+	}
+	return recovered, calls, nil
+}
+
+func appendUnique(dst []string, ids ...string) []string {
+	for _, id := range ids {
+		dup := false
+		for _, v := range dst {
+			if v == id {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			dst = append(dst, id)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/processor"
+	"ig-webhook/internal/store"
+	"ig-webhook/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type noWorkflows struct{}
+
+func (noWorkflows) ListActiveWorkflowsForIGAccount(string) ([]*types.WorkflowDefinition, error) {
+	return nil, nil
+}
+
+// fakeCommentsGraph: media m1 punya 3 halaman komentar (terbaru dulu).
+func fakeCommentsGraph(t *testing.T, now time.Time, calls *int32) *httptest.Server {
+	t.Helper()
+	ago := func(d time.Duration) string { return now.Add(-d).UTC().Format(time.RFC3339) }
+	pages := map[string]any{
+		"": map[string]any{
+			"data": []map[string]any{
+				{"id": "c1", "text": "halo", "timestamp": ago(10 * time.Minute), "from": map[string]string{"id": "u1", "username": "budi"},
+					"replies": map[string]any{"data": []map[string]any{{"id": "r1", "timestamp": ago(5 * time.Minute)}}}},
+				{"id": "c2", "timestamp": ago(20 * time.Minute)},
+			},
+			"paging": map[string]any{"cursors": map[string]string{"after": "p2"}, "next": "x"},
+		},
+		"p2": map[string]any{
+			"data":   []map[string]any{{"id": "c3", "timestamp": ago(30 * time.Minute), "username": "ani"}, {"id": "c4", "timestamp": ago(3 * time.Hour)}},
+			"paging": map[string]any{"cursors": map[string]string{"after": "p3"}, "next": "x"},
+		},
+		"p3": map[string]any{
+			"data": []map[string]any{{"id": "c5", "timestamp": ago(4 * time.Hour)}},
+		},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		page, ok := pages[r.URL.Query().Get("after")]
+		if r.URL.Path != "/v21.0/m1/comments" || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestReconciler(t *testing.T) (*CommentReconciler, *miniredis.Miniredis, *ig.Client, *int32) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	kv := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	var calls int32
+	srv := fakeCommentsGraph(t, time.Now(), &calls)
+	r := NewCommentReconciler(kv, fakeTokens{}, nil, noWorkflows{}, processor.NewCommentProcessor(kv, nil, noWorkflows{}))
+	r.NewClient = func(token string) *ig.Client {
+		c := ig.NewClient(token)
+		c.BaseURL = srv.URL
+		return c
+	}
+	return r, mr, r.NewClient("tok"), &calls
+}
+
+func TestReconcileMediaStopsAtLookback(t *testing.T) {
+	r, mr, client, calls := newTestReconciler(t)
+	// c2 sudah diterima lewat webhook
+	mr.Set("idem:event:c2", "1")
+
+	n, used, err := r.reconcileMedia(context.Background(), client, "acct", "m1", time.Now().Add(-2*time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// halaman 2 memuat komentar lebih lama dari lookback: p3 tidak diambil
+	if n != 3 || used != 2 || *calls != 2 {
+		t.Fatalf("recovered=%d used=%d calls=%d, want 3/2/2", n, used, *calls)
+	}
+	// balasan r1 ikut direkonsiliasi
+	for _, id := range []string{"c1", "r1", "c3"} {
+		if !mr.Exists("idem:event:" + id) {
+			t.Errorf("comment %s not processed", id)
+		}
+	}
+	if mr.Exists("idem:event:c4") {
+		t.Error("comment older than lookback processed")
+	}
+}
+
+func TestReconcileMediaRespectsBudget(t *testing.T) {
+	r, mr, client, calls := newTestReconciler(t)
+
+	n, used, err := r.reconcileMedia(context.Background(), client, "acct", "m1", time.Now().Add(-2*time.Hour), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || used != 1 || *calls != 1 {
+		t.Fatalf("recovered=%d used=%d calls=%d, want 3/1/1", n, used, *calls)
+	}
+	if mr.Exists("idem:event:c3") {
+		t.Error("second page fetched beyond budget")
+	}
+}
+
+func TestListCommentsIncludesReplies(t *testing.T) {
+	_, _, client, _ := newTestReconciler(t)
+	page, err := client.ListComments(context.Background(), "m1", "", commentsPageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range page.Comments {
+		ids = append(ids, c.ID+"<"+c.ParentID)
+	}
+	if want := []string{"c1<", "r1<c1", "c2<"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("comments %v, want %v", ids, want)
+	}
+}
+
+// lockTakeover: lock run berjalan kadaluarsa dan diambil run lain di tengah jalan.
+type lockTakeover struct{ mr *miniredis.Miniredis }
+
+func (l lockTakeover) ListActiveWorkflowsForIGAccount(acct string) ([]*types.WorkflowDefinition, error) {
+	l.mr.Set("lock:reconcile:comments:"+acct, "other")
+	return nil, nil
+}
+
+func TestReconcileAccountReleasesOnlyOwnLock(t *testing.T) {
+	r, mr, _, _ := newTestReconciler(t)
+	r.reconcileAccount(context.Background(), "acct")
+	if mr.Exists("lock:reconcile:comments:acct") {
+		t.Fatal("own lock not released")
+	}
+
+	r.Workflows = lockTakeover{mr}
+	r.reconcileAccount(context.Background(), "acct")
+	if v, _ := mr.Get("lock:reconcile:comments:acct"); v != "other" {
+		t.Fatalf("foreign lock released: %q", v)
+	}
+}
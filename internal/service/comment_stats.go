@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"ig-webhook/internal/processor"
+	"ig-webhook/internal/store"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const commentStatsTTL = 90 * 24 * time.Hour
+
+// CommentStats: counter harian komentar per akun, dipisah organik vs iklan.
+// Hash stats:comments:<acct>:<YYYY-MM-DD> (UTC) dengan field:
+//
+//	organic, organic.triggered, ad, ad.triggered, ad:<id>, ad:<id>.triggered
+type CommentStats struct {
+	KV *store.RedisStore
+}
+
+func NewCommentStats(kv *store.RedisStore) *CommentStats { return &CommentStats{KV: kv} }
+
+func commentStatsKey(acct string, day time.Time) string {
+	return "stats:comments:" + acct + ":" + day.UTC().Format("2006-01-02")
+}
+
+// judul iklan terakhir yang terlihat per ad id
+func adTitlesKey(acct string) string { return "stats:ads:" + acct }
+
+func statsDay(ev processor.CommentEvent) time.Time {
+	if ev.CommentedAt.IsZero() {
+		return time.Now()
+	}
+	return ev.CommentedAt
+}
+
+func (s *CommentStats) incr(ctx context.Context, ev processor.CommentEvent, suffix string) error {
+	key := commentStatsKey(ev.IGBusinessID, statsDay(ev))
+	if ev.AdID == "" {
+		return s.KV.HIncrBy(ctx, key, "organic"+suffix, 1, commentStatsTTL)
+	}
+	if err := s.KV.HIncrBy(ctx, key, "ad"+suffix, 1, commentStatsTTL); err != nil {
+		return err
+	}
+	return s.KV.HIncrBy(ctx, key, "ad:"+ev.AdID+suffix, 1, commentStatsTTL)
+}
+
+// RecordComment mencatat komentar masuk.
+func (s *CommentStats) RecordComment(ctx context.Context, ev processor.CommentEvent) error {
+	if ev.AdID != "" && ev.AdTitle != "" {
+		if err := s.KV.HSet(ctx, adTitlesKey(ev.IGBusinessID), ev.AdID, ev.AdTitle, commentStatsTTL); err != nil {
+			return err
+		}
+	}
+	return s.incr(ctx, ev, "")
+}
+
+// RecordTriggered mencatat komentar yang memicu minimal satu workflow.
+func (s *CommentStats) RecordTriggered(ctx context.Context, ev processor.CommentEvent) error {
+	return s.incr(ctx, ev, ".triggered")
+}
+
+type AdCommentStats struct {
+	AdID      string `json:"adId"`
+	AdTitle   string `json:"adTitle,omitempty"`
+	Comments  int64  `json:"comments"`
+	Triggered int64  `json:"triggered"`
+}
+
+type DailyCommentStats struct {
+	Date             string           `json:"date"`
+	Organic          int64            `json:"organic"`
+	OrganicTriggered int64            `json:"organicTriggered"`
+	Ad               int64            `json:"ad"`
+	AdTriggered      int64            `json:"adTriggered"`
+	Ads              []AdCommentStats `json:"ads,omitempty"`
+}
+
+// Daily: statistik `days` hari terakhir (UTC), terbaru dulu.
+func (s *CommentStats) Daily(ctx context.Context, acct string, days int) ([]DailyCommentStats, error) {
+	titles, err := s.KV.HGetAll(ctx, adTitlesKey(acct))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	out := make([]DailyCommentStats, 0, days)
+	for i := 0; i < days; i++ {
+		day := now.AddDate(0, 0, -i)
+		fields, err := s.KV.HGetAll(ctx, commentStatsKey(acct, day))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, parseDailyStats(day.Format("2006-01-02"), fields, titles))
+	}
+	return out, nil
+}
+
+func parseDailyStats(date string, fields, titles map[string]string) DailyCommentStats {
+	st := DailyCommentStats{Date: date}
+	ads := map[string]*AdCommentStats{}
+	for f, v := range fields {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		switch f {
+		case "organic":
+			st.Organic = n
+		case "organic.triggered":
+			st.OrganicTriggered = n
+		case "ad":
+			st.Ad = n
+		case "ad.triggered":
+			st.AdTriggered = n
+		default:
+			id, ok := strings.CutPrefix(f, "ad:")
+			if !ok {
+				continue
+			}
+			id, triggered := strings.CutSuffix(id, ".triggered")
+			a := ads[id]
+			if a == nil {
+				a = &AdCommentStats{AdID: id, AdTitle: titles[id]}
+				ads[id] = a
+			}
+			if triggered {
+				a.Triggered = n
+			} else {
+				a.Comments = n
+			}
+		}
+	}
+	for _, a := range ads {
+		st.Ads = append(st.Ads, *a)
+	}
+	sort.Slice(st.Ads, func(i, j int) bool { return st.Ads[i].Comments > st.Ads[j].Comments })
+	return st
+}
@@ -0,0 +1,28 @@
+package service
+
+import "testing"
+
+func TestParseDailyStats(t *testing.T) {
+	fields := map[string]string{
+		"organic":              "10",
+		"organic.triggered":    "4",
+		"ad":                   "7",
+		"ad.triggered":         "3",
+		"ad:111":               "5",
+		"ad:111.triggered":     "2",
+		"ad:222":               "2",
+		"ad:222.triggered":     "1",
+		"something.unexpected": "9",
+	}
+	st := parseDailyStats("2026-10-19", fields, map[string]string{"111": "Promo Oktober"})
+	if st.Organic != 10 || st.OrganicTriggered != 4 || st.Ad != 7 || st.AdTriggered != 3 {
+		t.Fatalf("unexpected totals: %+v", st)
+	}
+	if len(st.Ads) != 2 {
+		t.Fatalf("expected 2 ads, got %+v", st.Ads)
+	}
+	first := st.Ads[0]
+	if first.AdID != "111" || first.AdTitle != "Promo Oktober" || first.Comments != 5 || first.Triggered != 2 {
+		t.Fatalf("unexpected top ad: %+v", first)
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ig-webhook/internal/processor"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/store"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const deletionRecordTTL = 90 * 24 * time.Hour
+
+// DataDeletionService: deauthorize & data deletion (callback Meta).
+type DataDeletionService struct {
+	KV           *store.RedisStore
+	Inspector    *asynq.Inspector
+	Parked       *queue.Parker
+	Integrations *repo.IntegrationRepo
+	Status       *repo.IntegrationStatusLookup
+	Workflows    *repo.PGWorkflowRepo
+	Media        *repo.MediaRepo // nil = tanpa katalog media
+}
+
+// DeletionStatus disimpan di deletion:<confirmation code> untuk status URL.
+type DeletionStatus struct {
+	ConfirmationCode string     `json:"confirmationCode"`
+	Status           string     `json:"status"` // pending | completed | failed
+	RequestedAt      time.Time  `json:"requestedAt"`
+	CompletedAt      *time.Time `json:"completedAt,omitempty"`
+	Scope            string     `json:"scope"`
+}
+
+// DeletionScope: data yang dihapus Purge, ditampilkan di status URL.
+const DeletionScope = "Deletes the Instagram access token, media catalog, queued and parked tasks, " +
+	"and cached, rate-limit and cooldown data for the account. Comment events, contacts and " +
+	"workflow execution logs are not stored by this service and are out of scope."
+
+func deletionKey(code string) string { return "deletion:" + code }
+
+// Revoke: user mencabut app. Integrasi ditandai revoked, task yang antre
+// dibatalkan dan task yang di-park dibuang.
+func (s *DataDeletionService) Revoke(ctx context.Context, igBusinessID string) error {
+	if _, err := s.Status.SetStatus(ctx, igBusinessID, repo.StatusRevoked, "deauthorized by user"); err != nil {
+		return fmt.Errorf("set revoked: %w", err)
+	}
+	n, err := queue.CancelAccountTasks(s.Inspector, igBusinessID)
+	if err != nil {
+		return fmt.Errorf("cancel tasks: %w", err)
+	}
+	if err := s.Parked.Clear(ctx, igBusinessID); err != nil {
+		return fmt.Errorf("clear parked: %w", err)
+	}
+	log.Printf("[REVOKE] integration acct=%s cancelled_tasks=%d", igBusinessID, n)
+	return nil
+}
+
+func (s *DataDeletionService) SaveStatus(ctx context.Context, st DeletionStatus) error {
+	if st.Scope == "" {
+		st.Scope = DeletionScope
+	}
+	b, _ := json.Marshal(st)
+	return s.KV.Set(ctx, deletionKey(st.ConfirmationCode), string(b), deletionRecordTTL)
+}
+
+func (s *DataDeletionService) GetStatus(ctx context.Context, code string) (*DeletionStatus, error) {
+	raw, err := s.KV.Get(ctx, deletionKey(code))
+	if err != nil {
+		return nil, err
+	}
+	var st DeletionStatus
+	if err := json.Unmarshal([]byte(raw), &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Purge menghapus data akun IG yang disimpan service ini: token integrasi,
+// katalog media, task antre/park, dan key Redis (idempotensi, cooldown, rate limit, usage,
+// warm-up, timezone, catatan drop). Key per komentar (idem:event, ig:private_reply)
+// tidak terikat akun dan kadaluarsa sendiri (≤14 hari); cooldown scope post
+// dihapus untuk post yang ada di katalog media. Aman diulang.
+func (s *DataDeletionService) Purge(ctx context.Context, p queue.PurgeAccountDataPayload) error {
+	acct := p.IGBusinessID
+	if err := s.Revoke(ctx, acct); err != nil {
+		return err
+	}
+	if err := s.Integrations.ClearTokens(ctx, acct); err != nil {
+		return fmt.Errorf("clear tokens: %w", err)
+	}
+	// id post diambil sebelum katalog dihapus (cooldown scope post)
+	var postIDs []string
+	if s.Media != nil {
+		ids, err := s.Media.IDs(ctx, acct)
+		if err != nil {
+			return fmt.Errorf("list media: %w", err)
+		}
+		postIDs = ids
+	}
+
+	wfIDs, err := s.Workflows.ListWorkflowIDsForIGAccount(ctx, acct)
+	if err != nil {
+		return err
+	}
+	brandID := processor.BrandIDForAccount(acct)
+	patterns := []string{
+		"ig:token:" + acct,
+		"ig:status:" + acct,
+		"ig:connected:" + acct,
+		"ig:tz:" + acct,
+		"integration:event:" + acct,
+		"warmup:override:" + acct,
+		"usage:acct:" + acct,
+		"ig:media:sc:" + acct + ":*",
+		"ig:media:miss:" + acct + ":*",
+		"ig:comment:" + acct + ":*",
+		"ig:own_comment:" + acct + ":*",
+		"stats:comments:" + acct + ":*",
+		"stats:ads:" + acct,
+		"rl:acct:" + acct + ":*",
+		"rl:brand:" + brandID + ":*",
+		"cooldown:dm:" + brandID + ":*",
+	}
+	for _, wf := range wfIDs {
+		patterns = append(patterns,
+			"idem:exec:"+wf+":*",
+			"idem:reply_sent:"+wf+":*",
+			"cooldown:dm:wf:"+wf+":*",
+			"rl:wf:"+wf+":*",
+			"task:dropped:*:"+wf+":*",
+			"wf:next_post:"+wf+":*",
+		)
+	}
+	for _, post := range postIDs {
+		patterns = append(patterns, "cooldown:dm:post:"+post+":*")
+	}
+	total := 0
+	for _, pat := range patterns {
+		n, err := s.KV.DelByPattern(ctx, pat)
+		total += n
+		if err != nil {
+			return fmt.Errorf("delete %s: %w", pat, err)
+		}
+	}
+	if s.Media != nil {
+		if err := s.Media.DeleteAccount(ctx, acct); err != nil {
+			return fmt.Errorf("delete media catalog: %w", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	if err := s.SaveStatus(ctx, DeletionStatus{
+		ConfirmationCode: p.ConfirmationCode,
+		Status:           "completed",
+		RequestedAt:      p.RequestedAt,
+		CompletedAt:      &now,
+	}); err != nil {
+		return err
+	}
+	log.Printf("[PURGE] acct=%s code=%s redis_keys=%d", acct, p.ConfirmationCode, total)
+	return nil
+}
+
+// MarkFailed: purge gagal di percobaan terakhir (task akan di-archive).
+func (s *DataDeletionService) MarkFailed(ctx context.Context, p queue.PurgeAccountDataPayload, cause error) error {
+	log.Printf("[ERR] purge acct=%s code=%s failed: %v", p.IGBusinessID, p.ConfirmationCode, cause)
+	return s.SaveStatus(ctx, DeletionStatus{
+		ConfirmationCode: p.ConfirmationCode,
+		Status:           "failed",
+		RequestedAt:      p.RequestedAt,
+	})
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/store"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestDeletionStatusScopeAndFailure(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := &DataDeletionService{KV: store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))}
+	ctx := context.Background()
+	p := queue.PurgeAccountDataPayload{IGBusinessID: "a1", ConfirmationCode: "abc", RequestedAt: time.Now().UTC()}
+
+	if err := s.SaveStatus(ctx, DeletionStatus{ConfirmationCode: "abc", Status: "pending", RequestedAt: p.RequestedAt}); err != nil {
+		t.Fatal(err)
+	}
+	st, err := s.GetStatus(ctx, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != "pending" || st.Scope != DeletionScope {
+		t.Fatalf("pending status %+v", st)
+	}
+
+	if err := s.MarkFailed(ctx, p, errors.New("db down")); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ = s.GetStatus(ctx, "abc"); st.Status != "failed" || st.CompletedAt != nil {
+		t.Fatalf("failed status %+v", st)
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/repo"
+	"log"
+	"time"
+)
+
+// IntegrationUpserter: penyimpanan integrasi hasil OAuth (repo.IntegrationRepo).
+type IntegrationUpserter interface {
+	UpsertInstagram(ctx context.Context, in repo.InstagramConnection) (repo.InstagramUpsert, error)
+}
+
+// WarmupRestarter: mulai ulang warm-up akun (rate.Limiter).
+type WarmupRestarter interface {
+	RestartWarmup(ctx context.Context, igBusinessID string) error
+}
+
+// IGOnboardingService menjalankan alur connect Instagram: code → short-lived
+// token → long-lived token → profil → simpan integrasi.
+type IGOnboardingService struct {
+	Client *ig.Client
+	OAuth  ig.OAuthConfig
+	Store  IntegrationUpserter
+
+	// OnConnected dipanggil setelah integrasi tersimpan (opsional), mis.
+	// aktifkan status + enqueue ulang task yang di-park. previous = status
+	// sebelum connect ("" = integrasi baru); sekarang selalu active.
+	OnConnected func(ctx context.Context, accountID string, previous repo.IntegrationStatus)
+
+	// Warmup (opsional): warm-up diulang dari nol kalau akun pindah pemilik
+	// atau connect ulang setelah revoked; created_at baris lama tidak berubah.
+	Warmup WarmupRestarter
+}
+
+func NewIGOnboardingService(o ig.OAuthConfig, store IntegrationUpserter) *IGOnboardingService {
+	return &IGOnboardingService{Client: ig.NewClient(""), OAuth: o, Store: store}
+}
+
+type ConnectResult struct {
+	IntegrationID string     `json:"integrationId"`
+	AccountID     string     `json:"accountId"`
+	Username      string     `json:"username"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+}
+
+func (s *IGOnboardingService) Connect(ctx context.Context, userID, code string) (*ConnectResult, error) {
+	short, err := s.Client.ExchangeCode(ctx, s.OAuth, code)
+	if err != nil {
+		return nil, err
+	}
+	long, err := s.Client.ExchangeLongLivedToken(ctx, s.OAuth.AppSecret, short.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := s.Client.GetProfile(ctx, long.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if long.ExpiresIn > 0 {
+		t := time.Now().Add(time.Duration(long.ExpiresIn) * time.Second)
+		expiresAt = &t
+	}
+	saved, err := s.Store.UpsertInstagram(ctx, repo.InstagramConnection{
+		UserID:      userID,
+		AccountID:   profile.UserID,
+		AccessToken: long.AccessToken,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("save integration: %w", err)
+	}
+	if s.Warmup != nil && (saved.PreviousUserID != "" || saved.PreviousStatus == repo.StatusRevoked) {
+		if err := s.Warmup.RestartWarmup(ctx, profile.UserID); err != nil {
+			log.Printf("[ERR] restart warmup acct=%s: %v", profile.UserID, err)
+		}
+	}
+	if s.OnConnected != nil {
+		s.OnConnected(ctx, profile.UserID, saved.PreviousStatus)
+	}
+	if saved.PreviousUserID != "" {
+		log.Printf("[WARN] instagram acct=%s moved from user=%s to user=%s", profile.UserID, saved.PreviousUserID, userID)
+	}
+	log.Printf("[OK] instagram connected user=%s acct=%s username=%s", userID, profile.UserID, profile.Username)
+	return &ConnectResult{IntegrationID: saved.ID, AccountID: profile.UserID, Username: profile.Username, ExpiresAt: expiresAt}, nil
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeUpserter struct {
+	got  repo.InstagramConnection
+	prev *repo.InstagramUpsert // nil = connect ulang oleh pemilik dari needs_reauth
+}
+
+func (f *fakeUpserter) UpsertInstagram(_ context.Context, in repo.InstagramConnection) (repo.InstagramUpsert, error) {
+	f.got = in
+	if f.prev != nil {
+		return *f.prev, nil
+	}
+	return repo.InstagramUpsert{ID: "int-1", PreviousStatus: repo.StatusNeedsReauth}, nil
+}
+
+type fakeWarmup struct{ restarted []string }
+
+func (f *fakeWarmup) RestartWarmup(_ context.Context, acct string) error {
+	f.restarted = append(f.restarted, acct)
+	return nil
+}
+
+// fakeGraph meniru api.instagram.com & graph.instagram.com.
+func fakeGraph(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Method != http.MethodPost || r.Form.Get("code") != "good-code" || r.Form.Get("client_secret") != "app-secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"invalid code","type":"OAuthException","code":100}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"access_token":"short-tok","user_id":1789,"permissions":"instagram_business_basic"}]}`))
+	})
+	mux.HandleFunc("/access_token", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("grant_type") != "ig_exchange_token" || q.Get("access_token") != "short-tok" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "long-tok", "token_type": "bearer", "expires_in": 5184000})
+	})
+	mux.HandleFunc("/v21.0/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != "long-tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"bad token","type":"OAuthException","code":190}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"user_id":17841400000000001,"username":"brand.id","account_type":"BUSINESS"}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestOnboarding(t *testing.T, store IntegrationUpserter) *IGOnboardingService {
+	srv := fakeGraph(t)
+	s := NewIGOnboardingService(ig.OAuthConfig{AppID: "app", AppSecret: "app-secret", RedirectURI: "https://x/cb"}, store)
+	s.Client.BaseURL = srv.URL
+	s.Client.OAuthBaseURL = srv.URL
+	return s
+}
+
+func TestOnboardingConnect(t *testing.T) {
+	store := &fakeUpserter{}
+	s := newTestOnboarding(t, store)
+	var connected string
+	var previous repo.IntegrationStatus
+	s.OnConnected = func(_ context.Context, acct string, prev repo.IntegrationStatus) { connected, previous = acct, prev }
+
+	res, err := s.Connect(context.Background(), "user-1", "good-code")
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if res.AccountID != "17841400000000001" || res.Username != "brand.id" || res.IntegrationID != "int-1" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if store.got.UserID != "user-1" || store.got.AccountID != res.AccountID || store.got.AccessToken != "long-tok" {
+		t.Fatalf("unexpected upsert %+v", store.got)
+	}
+	if store.got.ExpiresAt == nil || time.Until(*store.got.ExpiresAt) < 59*24*time.Hour {
+		t.Fatalf("expected ~60d expiry, got %v", store.got.ExpiresAt)
+	}
+	if connected != res.AccountID || previous != repo.StatusNeedsReauth {
+		t.Fatalf("OnConnected not called with account and previous status, got %q %q", connected, previous)
+	}
+}
+
+func TestOnboardingConnectBadCode(t *testing.T) {
+	store := &fakeUpserter{}
+	s := newTestOnboarding(t, store)
+
+	_, err := s.Connect(context.Background(), "user-1", "bad-code")
+	if err == nil {
+		t.Fatalf("expected error for bad code")
+	}
+	if ig.Classify(err) != ig.ClassPermanent {
+		t.Fatalf("expected permanent graph error, got %v", err)
+	}
+	if store.got.AccountID != "" {
+		t.Fatalf("integration must not be saved on failure")
+	}
+}
+
+func TestOnboardingRestartsWarmupOnOwnerChangeOrRevoked(t *testing.T) {
+	cases := []struct {
+		name    string
+		prev    repo.InstagramUpsert
+		restart bool
+	}{
+		{"reconnect by owner", repo.InstagramUpsert{ID: "int-1", PreviousStatus: repo.StatusNeedsReauth}, false},
+		{"owner changed", repo.InstagramUpsert{ID: "int-1", PreviousStatus: repo.StatusActive, PreviousUserID: "user-0"}, true},
+		{"revoked", repo.InstagramUpsert{ID: "int-1", PreviousStatus: repo.StatusRevoked}, true},
+	}
+	for _, c := range cases {
+		store := &fakeUpserter{prev: &c.prev}
+		s := newTestOnboarding(t, store)
+		w := &fakeWarmup{}
+		s.Warmup = w
+
+		if _, err := s.Connect(context.Background(), "user-1", "good-code"); err != nil {
+			t.Fatalf("%s: connect: %v", c.name, err)
+		}
+		if got := len(w.restarted) == 1; got != c.restart {
+			t.Fatalf("%s: warmup restarted %v, want %v", c.name, w.restarted, c.restart)
+		}
+	}
+}
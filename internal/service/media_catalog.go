@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/store"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	mediaPageSize      = 50
+	mediaRefCacheTTL   = 24 * time.Hour
+	mediaMissCacheTTL  = 10 * time.Minute // shortcode tidak ditemukan / sync gagal: jangan sync ulang tiap event
+	mediaSyncInterval  = time.Minute      // jarak minimal sync on-demand per akun
+	defaultSyncMaxPage = 4
+)
+
+var ErrMediaNotFound = errors.New("media not found")
+
+// MediaRef: referensi post dari workflow — media id, shortcode atau permalink.
+type MediaRef struct {
+	ID        string // terisi kalau ref sudah berupa media id
+	Shortcode string
+}
+
+var (
+	mediaIDRe   = regexp.MustCompile(`^[0-9]+$`)
+	shortcodeRe = regexp.MustCompile(`^[A-Za-z0-9_-]{5,64}$`)
+)
+
+// ParseMediaRef menerima media id ("17895695668004550"), shortcode ("C1a2B3c4D5e")
+// atau permalink ("https://www.instagram.com/p/C1a2B3c4D5e/", juga /reel/ dan /tv/).
+func ParseMediaRef(ref string) (MediaRef, error) {
+	ref = strings.TrimSpace(ref)
+	switch {
+	case ref == "":
+		return MediaRef{}, fmt.Errorf("empty media reference")
+	case mediaIDRe.MatchString(ref):
+		return MediaRef{ID: ref}, nil
+	case strings.Contains(ref, "instagram.com/") || strings.Contains(ref, "instagr.am/"):
+		if !strings.Contains(ref, "://") {
+			ref = "https://" + ref
+		}
+		u, err := url.Parse(ref)
+		if err != nil {
+			return MediaRef{}, fmt.Errorf("invalid permalink %q: %w", ref, err)
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		// /p/<code>, /reel/<code>, /tv/<code>, juga /<username>/p/<code>
+		for i := 0; i+1 < len(parts); i++ {
+			switch parts[i] {
+			case "p", "reel", "reels", "tv":
+				if shortcodeRe.MatchString(parts[i+1]) {
+					return MediaRef{Shortcode: parts[i+1]}, nil
+				}
+			}
+		}
+		return MediaRef{}, fmt.Errorf("no shortcode in permalink %q", ref)
+	case shortcodeRe.MatchString(ref):
+		return MediaRef{Shortcode: ref}, nil
+	default:
+		return MediaRef{}, fmt.Errorf("unrecognized media reference %q", ref)
+	}
+}
+
+// MediaCatalog menyinkron media akun IG ke Postgres dan me-resolve
+// permalink/shortcode ke media id.
+type MediaCatalog struct {
+	Repo   *repo.MediaRepo
+	Tokens TokenResolver
+	KV     *store.RedisStore
+
+	// SyncMaxPages: halaman /me/media per sync (terbaru dulu)
+	SyncMaxPages int
+	NewClient    func(token string) *ig.Client
+}
+
+func NewMediaCatalog(r *repo.MediaRepo, tokens TokenResolver, kv *store.RedisStore) *MediaCatalog {
+	return &MediaCatalog{Repo: r, Tokens: tokens, KV: kv, SyncMaxPages: defaultSyncMaxPage, NewClient: ig.NewClient}
+}
+
+func toMediaRow(acct string, m ig.Media) repo.MediaRow {
+	row := repo.MediaRow{
+		ID:               m.ID,
+		AccountID:        acct,
+		Permalink:        m.Permalink,
+		Shortcode:        m.Shortcode,
+		Caption:          m.Caption,
+		MediaType:        m.MediaType,
+		MediaProductType: m.MediaProductType,
+	}
+	if row.Shortcode == "" && m.Permalink != "" {
+		if ref, err := ParseMediaRef(m.Permalink); err == nil {
+			row.Shortcode = ref.Shortcode
+		}
+	}
+	if !m.Timestamp.IsZero() {
+		t := m.Timestamp.UTC()
+		row.Timestamp = &t
+	}
+	return row
+}
+
+// Sync menarik media terbaru akun. Berhenti setelah SyncMaxPages halaman atau
+// saat satu halaman penuh sudah ada semua di katalog. Return media baru.
+func (c *MediaCatalog) Sync(ctx context.Context, acct string) (int, error) {
+	token, err := c.Tokens.Lookup(ctx, acct)
+	if err != nil {
+		return 0, err
+	}
+	client := c.NewClient(token)
+	added, after := 0, ""
+	for page := 0; page < c.SyncMaxPages; page++ {
+		mp, err := client.ListMedia(ctx, after, mediaPageSize)
+		if err != nil {
+			return added, err
+		}
+		rows := make([]repo.MediaRow, 0, len(mp.Media))
+		for _, m := range mp.Media {
+			rows = append(rows, toMediaRow(acct, m))
+		}
+		n, err := c.Repo.Upsert(ctx, rows)
+		added += n
+		if err != nil {
+			return added, err
+		}
+		if n == 0 || mp.After == "" {
+			break
+		}
+		after = mp.After
+	}
+	return added, nil
+}
+
+// SyncAll menyinkron semua akun (dipakai cron).
+func (c *MediaCatalog) SyncAll(ctx context.Context, accts []string) {
+	for _, acct := range accts {
+		if n, err := c.Sync(ctx, acct); err != nil {
+			log.Printf("[ERR] media sync acct=%s: %v", acct, err)
+		} else if n > 0 {
+			log.Printf("[MEDIA] acct=%s new media=%d", acct, n)
+		}
+	}
+}
+
+func mediaRefCacheKey(acct, shortcode string) string { return "ig:media:sc:" + acct + ":" + shortcode }
+
+// Resolve mengubah referensi post menjadi media id. Shortcode yang belum ada
+// di katalog memicu sync (sekali per mediaMissCacheTTL).
+func (c *MediaCatalog) Resolve(ctx context.Context, acct, ref string) (string, error) {
+	mr, err := ParseMediaRef(ref)
+	if err != nil {
+		return "", err
+	}
+	if mr.ID != "" {
+		return mr.ID, nil
+	}
+	cacheKey := mediaRefCacheKey(acct, mr.Shortcode)
+	if id, err := c.KV.Get(ctx, cacheKey); err == nil {
+		if id == "" {
+			return "", fmt.Errorf("%w: shortcode %s", ErrMediaNotFound, mr.Shortcode)
+		}
+		return id, nil
+	} else if !errors.Is(err, redis.Nil) {
+		log.Printf("[WARN] media ref cache: %v", err)
+	}
+
+	id, err := c.Repo.IDByShortcode(ctx, acct, mr.Shortcode)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		synced, err := c.syncOnDemand(ctx, acct)
+		if err != nil {
+			// sync gagal: negative-cache juga, supaya tiap event tidak memicu sync
+			_ = c.KV.Set(ctx, cacheKey, "", mediaMissCacheTTL)
+			return "", fmt.Errorf("sync media: %w", err)
+		}
+		if !synced {
+			// sync lain baru saja jalan (atau sedang jalan); jangan cache miss
+			if id, err = c.Repo.IDByShortcode(ctx, acct, mr.Shortcode); err != nil {
+				return "", err
+			}
+			if id == "" {
+				return "", fmt.Errorf("%w: shortcode %s", ErrMediaNotFound, mr.Shortcode)
+			}
+			_ = c.KV.Set(ctx, cacheKey, id, mediaRefCacheTTL)
+			return id, nil
+		}
+		if id, err = c.Repo.IDByShortcode(ctx, acct, mr.Shortcode); err != nil {
+			return "", err
+		}
+	}
+	if id == "" {
+		_ = c.KV.Set(ctx, cacheKey, "", mediaMissCacheTTL)
+		return "", fmt.Errorf("%w: shortcode %s", ErrMediaNotFound, mr.Shortcode)
+	}
+	_ = c.KV.Set(ctx, cacheKey, id, mediaRefCacheTTL)
+	return id, nil
+}
+
+// syncOnDemand menjalankan Sync paling sering sekali per mediaSyncInterval per
+// akun; false berarti dilewati karena sync lain baru saja dimulai.
+func (c *MediaCatalog) syncOnDemand(ctx context.Context, acct string) (bool, error) {
+	ok, err := c.KV.AcquireOnce(ctx, "ig:media:sync:"+acct, mediaSyncInterval)
+	if err != nil {
+		log.Printf("[WARN] media sync throttle acct=%s: %v", acct, err)
+	} else if !ok {
+		return false, nil
+	}
+	_, err = c.Sync(ctx, acct)
+	return true, err
+}
+
+func mediaMissCacheKey(acct, mediaID string) string { return "ig:media:miss:" + acct + ":" + mediaID }
+
+// Media mengambil metadata media dari katalog; media yang belum ada diambil
+// dari Graph API lalu disimpan. Media yang gagal diambil di-negative-cache
+// selama mediaMissCacheTTL supaya tiap event tidak memanggil Graph API lagi.
+func (c *MediaCatalog) Media(ctx context.Context, acct, mediaID string) (*repo.MediaRow, error) {
+	row, err := c.Repo.Get(ctx, mediaID)
+	if err != nil || row != nil {
+		return row, err
+	}
+	missKey := mediaMissCacheKey(acct, mediaID)
+	if miss, err := c.KV.Exists(ctx, missKey); err != nil {
+		log.Printf("[WARN] media miss cache: %v", err)
+	} else if miss {
+		return nil, fmt.Errorf("%w: media %s (cached miss)", ErrMediaNotFound, mediaID)
+	}
+	token, err := c.Tokens.Lookup(ctx, acct)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.NewClient(token).GetMedia(ctx, mediaID)
+	if err != nil {
+		_ = c.KV.Set(ctx, missKey, "1", mediaMissCacheTTL)
+		return nil, err
+	}
+	fetched := toMediaRow(acct, *m)
+	if _, err := c.Repo.Upsert(ctx, []repo.MediaRow{fetched}); err != nil {
+		log.Printf("[WARN] cache media %s: %v", mediaID, err)
+	}
+	return &fetched, nil
+}
+
+// FirstMediaAfter: media paling awal di katalog yang terbit setelah t.
+func (c *MediaCatalog) FirstMediaAfter(ctx context.Context, acct string, t time.Time) (*repo.MediaRow, error) {
+	return c.Repo.FirstAfter(ctx, acct, t)
+}
+
+// RecentMedia: media terbaru akun di katalog.
+func (c *MediaCatalog) RecentMedia(ctx context.Context, acct string, limit int) ([]repo.MediaRow, error) {
+	return c.Repo.Recent(ctx, acct, limit)
+}
+
+// ResolveAll me-resolve semua referensi; referensi yang gagal dilewati dan
+// dilaporkan di errs (key = referensi asli).
+func (c *MediaCatalog) ResolveAll(ctx context.Context, acct string, refs []string) ([]string, map[string]error) {
+	var ids []string
+	errs := map[string]error{}
+	for _, ref := range refs {
+		id, err := c.Resolve(ctx, acct, ref)
+		if err != nil {
+			errs[ref] = err
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, errs
+}
@@ -0,0 +1,32 @@
+package service
+
+import "testing"
+
+func TestParseMediaRef(t *testing.T) {
+	cases := []struct {
+		in        string
+		id, code  string
+		wantError bool
+	}{
+		{in: "17895695668004550", id: "17895695668004550"},
+		{in: " C1a2B3c4D5e ", code: "C1a2B3c4D5e"},
+		{in: "https://www.instagram.com/p/C1a2B3c4D5e/", code: "C1a2B3c4D5e"},
+		{in: "https://www.instagram.com/reel/C9xYz_-12ab/?igsh=abc", code: "C9xYz_-12ab"},
+		{in: "instagram.com/brand.id/p/C1a2B3c4D5e", code: "C1a2B3c4D5e"},
+		{in: "https://www.instagram.com/brand.id/", wantError: true},
+		{in: "not a ref!", wantError: true},
+		{in: "", wantError: true},
+	}
+	for _, c := range cases {
+		got, err := ParseMediaRef(c.in)
+		if c.wantError {
+			if err == nil {
+				t.Errorf("%q: expected error, got %+v", c.in, got)
+			}
+			continue
+		}
+		if err != nil || got.ID != c.id || got.Shortcode != c.code {
+			t.Errorf("%q: got %+v, %v", c.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/store"
+	"log"
+)
+
+// TokenReencryptService mengenkripsi ulang integration.access_token dengan
+// KEK aktif (rotasi key / migrasi dari plaintext).
+type TokenReencryptService struct {
+	Repo      *repo.IntegrationRepo
+	KV        *store.RedisStore
+	BatchSize int
+}
+
+func NewTokenReencryptService(r *repo.IntegrationRepo, kv *store.RedisStore) *TokenReencryptService {
+	return &TokenReencryptService{Repo: r, KV: kv, BatchSize: 200}
+}
+
+// Run memproses semua baris dan menghapus cache ig:token akun yang berubah,
+// supaya cache terenkripsi KEK lama tidak bertahan sampai token expiry.
+// Aman dijalankan ulang.
+func (s *TokenReencryptService) Run(ctx context.Context) (int, error) {
+	total := 0
+	after := ""
+	for {
+		next, changed, err := s.Repo.ReencryptBatch(ctx, after, s.BatchSize)
+		total += len(changed)
+		for _, acct := range changed {
+			if err := s.KV.Del(ctx, repo.TokenCacheKey(acct)); err != nil {
+				log.Printf("[WARN] invalidate token cache acct=%s: %v", acct, err)
+			}
+		}
+		if err != nil {
+			return total, err
+		}
+		if next == "" {
+			return total, nil
+		}
+		after = next
+	}
+}
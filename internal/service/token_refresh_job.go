@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/store"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	refreshLockTTL     = 5 * time.Minute
+	refreshFailTTL     = 30 * 24 * time.Hour
+	refreshFailBackoff = 6 * time.Hour // jangan coba ulang integrasi gagal tiap run
+	refreshMaxPages    = 10            // halaman ListExpiring per run (melewati yang di-backoff)
+)
+
+// TokenRefresher me-refresh token satu integrasi (IGRefreshService).
+type TokenRefresher interface {
+	RefreshToken(ctx context.Context, integrationID, userID string) (time.Time, error)
+}
+
+// IntegrationStatusSetter menyimpan status integrasi (repo.IntegrationStatusLookup).
+type IntegrationStatusSetter interface {
+	SetStatus(ctx context.Context, accountID string, s repo.IntegrationStatus, reason string) (bool, error)
+}
+
+// TokenRefreshJob me-refresh long-lived token yang akan kadaluarsa dalam
+// Window. Aman dijalankan paralel di beberapa instance (lock per integrasi).
+type TokenRefreshJob struct {
+	Refresher    TokenRefresher
+	Integrations *repo.IntegrationRepo
+	KV           *store.RedisStore
+	Window       time.Duration
+	BatchSize    int
+
+	// Token ditolak Meta saat refresh → integrasi needs_reauth + event
+	// (nil = hanya dicatat di ig:refresh:failed)
+	Status IntegrationStatusSetter
+	Events *asynq.Client
+}
+
+func NewTokenRefreshJob(s *IGRefreshService, kv *store.RedisStore, window time.Duration) *TokenRefreshJob {
+	return &TokenRefreshJob{Refresher: s, Integrations: s.Repo, KV: kv, Window: window, BatchSize: 100}
+}
+
+// RefreshFailure dicatat di Redis (ig:refresh:failed:<integration id>)
+// untuk ditindaklanjuti (mis. minta user re-auth).
+type RefreshFailure struct {
+	IntegrationID string        `json:"integrationId"`
+	AccountID     string        `json:"accountId"`
+	ExpiresAt     time.Time     `json:"expiresAt"`
+	Error         string        `json:"error"`
+	Class         ig.ErrorClass `json:"class"`
+	Attempts      int           `json:"attempts"`
+	At            time.Time     `json:"at"`
+}
+
+func refreshFailKey(integrationID string) string {
+	return "ig:refresh:failed:" + integrationID
+}
+
+func (j *TokenRefreshJob) Run(ctx context.Context) {
+	// integrasi yang sedang backoff (status di Redis) dilewati dan halaman
+	// berikutnya diambil, supaya tidak menutupi kandidat lain di batch
+	var ok, failed, skipped, attempted int
+	var after *repo.ExpiringIntegration
+	for page := 0; page < refreshMaxPages && attempted < j.BatchSize; page++ {
+		items, err := j.Integrations.ListExpiring(ctx, j.Window, after, j.BatchSize)
+		if err != nil {
+			log.Printf("[ERR] token refresh: list expiring: %v", err)
+			break
+		}
+		for _, it := range items {
+			if attempted >= j.BatchSize || ctx.Err() != nil {
+				break
+			}
+			switch err := j.refreshOne(ctx, it); {
+			case err == nil:
+				ok++
+				attempted++
+			case errors.Is(err, errSkipped):
+				skipped++
+			default:
+				failed++
+				attempted++
+			}
+		}
+		if len(items) < j.BatchSize {
+			break
+		}
+		after = &items[len(items)-1]
+	}
+	if attempted+skipped > 0 {
+		log.Printf("[CRON] token refresh: refreshed=%d failed=%d skipped=%d", ok, failed, skipped)
+	}
+}
+
+var errSkipped = errors.New("skipped")
+
+func (j *TokenRefreshJob) refreshOne(ctx context.Context, it repo.ExpiringIntegration) error {
+	prev, hasPrev := j.lastFailure(ctx, it.ID)
+	if hasPrev && time.Since(prev.At) < refreshFailBackoff {
+		return errSkipped
+	}
+
+	lockKey, lockToken := "lock:ig:refresh:"+it.ID, uuid.NewString()
+	got, err := j.KV.SetNX(ctx, lockKey, lockToken, refreshLockTTL)
+	if err != nil || !got {
+		return errSkipped // instance lain sedang me-refresh
+	}
+	// lepas lock hanya kalau masih milik kita (bisa sudah expire & diambil instance lain)
+	defer func() { _, _ = j.KV.DelIfEqual(context.Background(), lockKey, lockToken) }()
+
+	expiresAt, err := j.Refresher.RefreshToken(ctx, it.ID, it.UserID)
+	if err != nil {
+		f := RefreshFailure{
+			IntegrationID: it.ID,
+			AccountID:     it.AccountID,
+			ExpiresAt:     it.ExpiresAt,
+			Error:         err.Error(),
+			Class:         ig.Classify(err),
+			Attempts:      prev.Attempts + 1,
+			At:            time.Now().UTC(),
+		}
+		b, _ := json.Marshal(f)
+		if err := j.KV.Set(ctx, refreshFailKey(it.ID), string(b), refreshFailTTL); err != nil {
+			log.Printf("[ERR] record refresh failure integration=%s: %v", it.ID, err)
+		}
+		log.Printf("[ERR] token refresh integration=%s acct=%s class=%s attempts=%d: %v",
+			it.ID, it.AccountID, f.Class, f.Attempts, err)
+		if f.Class == ig.ClassTokenInvalid {
+			j.markNeedsReauth(ctx, it.AccountID, err)
+		}
+		return err
+	}
+
+	// token lama di cache tidak dipakai lagi
+	if it.AccountID != "" {
+		if err := j.KV.Del(ctx, repo.TokenCacheKey(it.AccountID)); err != nil {
+			log.Printf("[WARN] invalidate token cache acct=%s: %v", it.AccountID, err)
+		}
+	}
+	if hasPrev {
+		_ = j.KV.Del(ctx, refreshFailKey(it.ID))
+	}
+	log.Printf("[OK] token refreshed integration=%s acct=%s expires=%s",
+		it.ID, it.AccountID, expiresAt.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// markNeedsReauth: token dicabut/kadaluarsa, refresh tidak akan pernah
+// berhasil; brand perlu connect ulang.
+func (j *TokenRefreshJob) markNeedsReauth(ctx context.Context, accountID string, cause error) {
+	if j.Status == nil || accountID == "" {
+		return
+	}
+	changed, err := j.Status.SetStatus(ctx, accountID, repo.StatusNeedsReauth, "token refresh: "+cause.Error())
+	if err != nil {
+		log.Printf("[ERR] set integration status acct=%s status=%s: %v", accountID, repo.StatusNeedsReauth, err)
+		return
+	}
+	if !changed {
+		return
+	}
+	queue.EmitIntegrationStatusChanged(ctx, j.Events, queue.IntegrationStatusChangedPayload{
+		IGBusinessID: accountID,
+		Status:       string(repo.StatusNeedsReauth),
+		Class:        string(ig.ClassTokenInvalid),
+		Reason:       cause.Error(),
+		At:           time.Now().UTC(),
+	})
+}
+
+func (j *TokenRefreshJob) lastFailure(ctx context.Context, integrationID string) (RefreshFailure, bool) {
+	raw, err := j.KV.Get(ctx, refreshFailKey(integrationID))
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("[WARN] read refresh failure integration=%s: %v", integrationID, err)
+		}
+		return RefreshFailure{}, false
+	}
+	var f RefreshFailure
+	if json.Unmarshal([]byte(raw), &f) != nil {
+		return RefreshFailure{}, false
+	}
+	return f, true
+}
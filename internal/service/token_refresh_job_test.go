@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/queue"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/store"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+type fakeRefresher struct {
+	calls int
+	err   error
+}
+
+func (f *fakeRefresher) RefreshToken(context.Context, string, string) (time.Time, error) {
+	f.calls++
+	return time.Now().Add(60 * 24 * time.Hour), f.err
+}
+
+type fakeStatusSetter struct {
+	set map[string]repo.IntegrationStatus
+}
+
+func (f *fakeStatusSetter) SetStatus(_ context.Context, acct string, s repo.IntegrationStatus, _ string) (bool, error) {
+	changed := f.set[acct] != s
+	f.set[acct] = s
+	return changed, nil
+}
+
+func newTestRefreshJob(t *testing.T) (*TokenRefreshJob, *miniredis.Miniredis, *fakeRefresher, *fakeStatusSetter, *asynq.Inspector) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	opt := asynq.RedisClientOpt{Addr: mr.Addr()}
+	events := asynq.NewClient(opt)
+	t.Cleanup(func() { _ = events.Close() })
+	insp := asynq.NewInspector(opt)
+	t.Cleanup(func() { _ = insp.Close() })
+	ref := &fakeRefresher{}
+	st := &fakeStatusSetter{set: map[string]repo.IntegrationStatus{}}
+	j := &TokenRefreshJob{
+		Refresher: ref,
+		KV:        store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})),
+		Window:    7 * 24 * time.Hour,
+		BatchSize: 10,
+		Status:    st,
+		Events:    events,
+	}
+	return j, mr, ref, st, insp
+}
+
+var expiring = repo.ExpiringIntegration{ID: "i1", AccountID: "a1", UserID: "u1", ExpiresAt: time.Now().Add(24 * time.Hour)}
+
+func TestRefreshOneSkipsBackoffAndLockedIntegrations(t *testing.T) {
+	j, mr, ref, _, _ := newTestRefreshJob(t)
+	ctx := context.Background()
+
+	b, _ := json.Marshal(RefreshFailure{IntegrationID: "i1", At: time.Now().Add(-time.Hour), Attempts: 1})
+	mr.Set(refreshFailKey("i1"), string(b))
+	if err := j.refreshOne(ctx, expiring); !errors.Is(err, errSkipped) {
+		t.Fatalf("backoff: got %v, want skipped", err)
+	}
+
+	mr.Del(refreshFailKey("i1"))
+	mr.Set("lock:ig:refresh:i1", "other-instance")
+	if err := j.refreshOne(ctx, expiring); !errors.Is(err, errSkipped) {
+		t.Fatalf("lock held: got %v, want skipped", err)
+	}
+	if ref.calls != 0 {
+		t.Fatalf("refresh called %d times while skipped", ref.calls)
+	}
+	if v, _ := mr.Get("lock:ig:refresh:i1"); v != "other-instance" {
+		t.Fatalf("foreign lock released: %q", v)
+	}
+}
+
+func TestRefreshOneClassifiesFailures(t *testing.T) {
+	cases := []struct {
+		name       string
+		err        error
+		wantStatus repo.IntegrationStatus
+	}{
+		{"token invalid", &ig.GraphError{Code: 190, Subcode: 460, Class: ig.ClassTokenInvalid}, repo.StatusNeedsReauth},
+		{"transient", &ig.GraphError{Code: 1, IsTransient: true, Class: ig.ClassRetryable}, ""},
+	}
+	for _, c := range cases {
+		j, mr, ref, st, insp := newTestRefreshJob(t)
+		ref.err = c.err
+
+		if err := j.refreshOne(context.Background(), expiring); err == nil {
+			t.Fatalf("%s: expected error", c.name)
+		}
+		raw, err := mr.Get(refreshFailKey("i1"))
+		if err != nil {
+			t.Fatalf("%s: failure not recorded", c.name)
+		}
+		var f RefreshFailure
+		_ = json.Unmarshal([]byte(raw), &f)
+		if f.Attempts != 1 || f.Class == "" {
+			t.Fatalf("%s: failure record %+v", c.name, f)
+		}
+		if st.set["a1"] != c.wantStatus {
+			t.Fatalf("%s: status %q, want %q", c.name, st.set["a1"], c.wantStatus)
+		}
+		events, _ := insp.ListPendingTasks(queue.QueuePriority)
+		if wantEvent := c.wantStatus != ""; (len(events) == 1) != wantEvent {
+			t.Fatalf("%s: %d status events, want event=%v", c.name, len(events), wantEvent)
+		}
+		if mr.Exists("lock:ig:refresh:i1") {
+			t.Fatalf("%s: lock not released", c.name)
+		}
+	}
+}
+
+func TestRefreshOneInvalidatesCacheOnSuccess(t *testing.T) {
+	j, mr, ref, _, _ := newTestRefreshJob(t)
+	mr.Set(repo.TokenCacheKey("a1"), `{"token":"old"}`)
+	b, _ := json.Marshal(RefreshFailure{IntegrationID: "i1", At: time.Now().Add(-7 * time.Hour), Attempts: 2})
+	mr.Set(refreshFailKey("i1"), string(b))
+
+	if err := j.refreshOne(context.Background(), expiring); err != nil {
+		t.Fatal(err)
+	}
+	if ref.calls != 1 {
+		t.Fatalf("refresh calls %d, want 1", ref.calls)
+	}
+	if mr.Exists(repo.TokenCacheKey("a1")) {
+		t.Fatal("token cache not invalidated")
+	}
+	if mr.Exists(refreshFailKey("i1")) {
+		t.Fatal("failure record not cleared")
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"ig-webhook/internal/ig"
+	"ig-webhook/internal/repo"
+	"ig-webhook/internal/store"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenResolver: token IG per akun (repo.IGTokenLookup).
+type TokenResolver interface {
+	Lookup(ctx context.Context, accountID string) (string, error)
+}
+
+// WebhookSubscriptionService mengelola subscribed_apps (field webhook) per akun IG.
+type WebhookSubscriptionService struct {
+	Tokens       TokenResolver
+	Integrations *repo.IntegrationRepo
+	KV           *store.RedisStore
+	Fields       []string // field yang diharapkan; default ig.DefaultWebhookFields
+
+	// NewClient bisa diganti di test (fake Graph server)
+	NewClient func(token string) *ig.Client
+}
+
+func NewWebhookSubscriptionService(tokens TokenResolver, integrations *repo.IntegrationRepo, kv *store.RedisStore) *WebhookSubscriptionService {
+	return &WebhookSubscriptionService{
+		Tokens:       tokens,
+		Integrations: integrations,
+		KV:           kv,
+		Fields:       ig.DefaultWebhookFields,
+		NewClient:    ig.NewClient,
+	}
+}
+
+func (s *WebhookSubscriptionService) client(ctx context.Context, accountID string) (*ig.Client, error) {
+	token, err := s.Tokens.Lookup(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.NewClient(token), nil
+}
+
+func (s *WebhookSubscriptionService) List(ctx context.Context, accountID string) ([]string, error) {
+	c, err := s.client(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return c.SubscribedFields(ctx)
+}
+
+// Subscribe menyetel field webhook akun (kosong = field default).
+func (s *WebhookSubscriptionService) Subscribe(ctx context.Context, accountID string, fields []string) error {
+	if len(fields) == 0 {
+		fields = s.Fields
+	}
+	c, err := s.client(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return c.Subscribe(ctx, fields)
+}
+
+func (s *WebhookSubscriptionService) Unsubscribe(ctx context.Context, accountID string) error {
+	c, err := s.client(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return c.Unsubscribe(ctx)
+}
+
+// Ensure men-subscribe ulang kalau ada field yang hilang; field tambahan
+// yang sudah aktif dipertahankan. Return field yang ditambahkan.
+func (s *WebhookSubscriptionService) Ensure(ctx context.Context, accountID string) ([]string, error) {
+	c, err := s.client(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	have, err := c.SubscribedFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+	missing := ig.MissingFields(have, s.Fields)
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	want := append(append([]string{}, have...), missing...)
+	sort.Strings(want)
+	if err := c.Subscribe(ctx, want); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+const reconcileLockTTL = 30 * time.Minute
+
+// Reconcile memeriksa semua integrasi aktif dan memperbaiki subscription
+// yang bergeser. Satu instance saja yang berjalan (lock Redis).
+func (s *WebhookSubscriptionService) Reconcile(ctx context.Context) {
+	const lockKey = "lock:webhook:subscriptions"
+	lockToken := uuid.NewString()
+	got, err := s.KV.SetNX(ctx, lockKey, lockToken, reconcileLockTTL)
+	if err != nil || !got {
+		return
+	}
+	// lepas hanya kalau lock masih milik run ini (bisa sudah kadaluarsa)
+	defer func() { _, _ = s.KV.DelIfEqual(context.Background(), lockKey, lockToken) }()
+
+	accts, err := s.Integrations.ListActiveAccountIDs(ctx)
+	if err != nil {
+		log.Printf("[ERR] subscription reconcile: list accounts: %v", err)
+		return
+	}
+	fixed, failed := 0, 0
+	for _, acct := range accts {
+		added, err := s.Ensure(ctx, acct)
+		if err != nil {
+			failed++
+			log.Printf("[ERR] subscription reconcile acct=%s: %v", acct, err)
+			continue
+		}
+		if len(added) > 0 {
+			fixed++
+			log.Printf("[SUBSCRIBE] acct=%s re-subscribed fields=%v", acct, added)
+		}
+	}
+	log.Printf("[CRON] subscription reconcile: accounts=%d fixed=%d failed=%d", len(accts), fixed, failed)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"ig-webhook/internal/ig"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeTokens struct{}
+
+func (fakeTokens) Lookup(context.Context, string) (string, error) { return "tok", nil }
+
+// fakeSubscribedApps meniru /me/subscribed_apps; POST dicatat di posted.
+func fakeSubscribedApps(t *testing.T, have []string, posted *[]string) func(string) *ig.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v21.0/me/subscribed_apps" || r.URL.Query().Get("access_token") != "tok" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"subscribed_fields": have}}})
+		case http.MethodPost:
+			*posted = strings.Split(r.URL.Query().Get("subscribed_fields"), ",")
+			_, _ = w.Write([]byte(`{"success":true}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return func(token string) *ig.Client {
+		c := ig.NewClient(token)
+		c.BaseURL = srv.URL
+		return c
+	}
+}
+
+func TestWebhookSubscriptionsEnsure(t *testing.T) {
+	ctx := context.Background()
+
+	var posted []string
+	s := NewWebhookSubscriptionService(fakeTokens{}, nil, nil)
+	s.NewClient = fakeSubscribedApps(t, []string{"comments", "mentions", "story_insights"}, &posted)
+	added, err := s.Ensure(ctx, "acct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"messages", "messaging_postbacks"}; !reflect.DeepEqual(added, want) {
+		t.Fatalf("added %v, want %v", added, want)
+	}
+	// field tambahan yang sudah aktif tetap dipertahankan
+	if want := []string{"comments", "mentions", "messages", "messaging_postbacks", "story_insights"}; !reflect.DeepEqual(posted, want) {
+		t.Fatalf("subscribed %v, want %v", posted, want)
+	}
+
+	posted = nil
+	s.NewClient = fakeSubscribedApps(t, append([]string{"story_insights"}, ig.DefaultWebhookFields...), &posted)
+	if added, err := s.Ensure(ctx, "acct"); err != nil || len(added) != 0 {
+		t.Fatalf("complete subscription: added %v, %v", added, err)
+	}
+	if posted != nil {
+		t.Fatalf("complete subscription must not re-subscribe, posted %v", posted)
+	}
+}
@@ -20,6 +20,11 @@ func (s *RedisStore) AcquireOnce(ctx context.Context, key string, ttl time.Durat
 	return s.rdb.SetNX(ctx, key, "1", ttl).Result()
 }
 
+// SetNX menyimpan val hanya kalau key belum ada.
+func (s *RedisStore) SetNX(ctx context.Context, key, val string, ttl time.Duration) (bool, error) {
+	return s.rdb.SetNX(ctx, key, val, ttl).Result()
+}
+
 func (s *RedisStore) IncrWithTTL(ctx context.Context, key string, ttl time.Duration) (int64, error) {
 	v := s.rdb.Incr(ctx, key)
 	if v.Err() != nil {
@@ -37,3 +42,85 @@ func (s *RedisStore) Get(ctx context.Context, key string) (string, error) {
 func (s *RedisStore) Set(ctx context.Context, key string, val string, ttl time.Duration) error {
 	return s.rdb.Set(ctx, key, val, ttl).Err()
 }
+
+func (s *RedisStore) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := s.rdb.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+// RunScript menjalankan Lua script (EVALSHA dengan fallback EVAL).
+func (s *RedisStore) RunScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	return script.Run(ctx, s.rdb, keys, args...).Result()
+}
+
+func (s *RedisStore) Del(ctx context.Context, keys ...string) error {
+	return s.rdb.Del(ctx, keys...).Err()
+}
+
+var delIfEqualScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// DelIfEqual menghapus key hanya kalau nilainya masih val (lepas klaim/lock milik sendiri).
+func (s *RedisStore) DelIfEqual(ctx context.Context, key, val string) (bool, error) {
+	n, err := delIfEqualScript.Run(ctx, s.rdb, []string{key}, val).Int()
+	return n > 0, err
+}
+
+func (s *RedisStore) HSet(ctx context.Context, key, field, val string, ttl time.Duration) error {
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, key, field, val)
+	if ttl > 0 {
+		pipe.Expire(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// HIncrBy menambah counter field hash; TTL key diperpanjang tiap increment.
+func (s *RedisStore) HIncrBy(ctx context.Context, key, field string, n int64, ttl time.Duration) error {
+	pipe := s.rdb.TxPipeline()
+	pipe.HIncrBy(ctx, key, field, n)
+	if ttl > 0 {
+		pipe.Expire(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return s.rdb.HGetAll(ctx, key).Result()
+}
+
+func (s *RedisStore) HDel(ctx context.Context, key string, fields ...string) error {
+	return s.rdb.HDel(ctx, key, fields...).Err()
+}
+
+// DelByPattern menghapus semua key yang cocok dengan pattern (SCAN + DEL).
+func (s *RedisStore) DelByPattern(ctx context.Context, pattern string) (int, error) {
+	n := 0
+	iter := s.rdb.Scan(ctx, 0, pattern, 500).Iterator()
+	batch := make([]string, 0, 500)
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			if err := s.rdb.Del(ctx, batch...).Err(); err != nil {
+				return n, err
+			}
+			n += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return n, err
+	}
+	if len(batch) > 0 {
+		if err := s.rdb.Del(ctx, batch...).Err(); err != nil {
+			return n, err
+		}
+		n += len(batch)
+	}
+	return n, nil
+}
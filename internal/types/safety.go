@@ -0,0 +1,121 @@
+package types
+
+import "strings"
+
+const (
+	SafetyModeConservative = "conservative"
+	SafetyModeBalanced     = "balanced"
+	SafetyModeAggressive   = "aggressive"
+)
+
+const (
+	CooldownScopeBrand    = "brand"
+	CooldownScopeWorkflow = "workflow"
+	CooldownScopePost     = "post"
+	CooldownScopeNone     = "none"
+)
+
+// Cooldown default = perilaku lama: 24 jam per brand+user.
+const defaultCooldownMinutes = 24 * 60
+
+type safetyPreset struct {
+	Limits       SafetyCombinedLimits
+	ContentRules SafetyContentRules
+}
+
+// Preset per mode. Balanced = angka default lama (25/jam, 200/hari).
+var safetyPresets = map[string]safetyPreset{
+	SafetyModeConservative: {
+		Limits: SafetyCombinedLimits{
+			MaxActionsPerHour:   10,
+			MaxActionsPerDay:    50,
+			DelayBetweenActions: [2]int{60, 180},
+			CommentToDmDelay:    [2]int{120, 300},
+		},
+		ContentRules: SafetyContentRules{MaxMentions: 1, MaxHashtags: 2},
+	},
+	SafetyModeBalanced: {
+		Limits: SafetyCombinedLimits{
+			MaxActionsPerHour:   25,
+			MaxActionsPerDay:    200,
+			DelayBetweenActions: [2]int{30, 90},
+			CommentToDmDelay:    [2]int{60, 180},
+		},
+		ContentRules: SafetyContentRules{MaxMentions: 2, MaxHashtags: 3},
+	},
+	SafetyModeAggressive: {
+		Limits: SafetyCombinedLimits{
+			MaxActionsPerHour:   50,
+			MaxActionsPerDay:    400,
+			DelayBetweenActions: [2]int{10, 30},
+			CommentToDmDelay:    [2]int{15, 60},
+		},
+		ContentRules: SafetyContentRules{MaxMentions: 3, MaxHashtags: 5},
+	},
+}
+
+// NormalizedMode mengembalikan nama preset yang dikenal; mode kosong/tidak dikenal → balanced.
+func (s SafetyConfig) NormalizedMode() string {
+	m := strings.ToLower(strings.TrimSpace(s.Mode))
+	if _, ok := safetyPresets[m]; ok {
+		return m
+	}
+	return SafetyModeBalanced
+}
+
+// Effective mengembalikan konfigurasi yang dipakai pipeline.
+//   - Enabled=false: tanpa limit; ActionTypes dan delay tetap sesuai workflow.
+//   - Enabled=true: nilai limit/delay/content rule yang 0 diisi dari preset Mode.
+func (s SafetyConfig) Effective() SafetyConfig {
+	out := s
+	out.Cooldown = s.EffectiveCooldown()
+	if !s.Enabled {
+		out.CombinedLimits.MaxActionsPerHour = 0
+		out.CombinedLimits.MaxActionsPerDay = 0
+		return out
+	}
+
+	out.Mode = s.NormalizedMode()
+	p := safetyPresets[out.Mode]
+
+	l := &out.CombinedLimits
+	if l.MaxActionsPerHour <= 0 {
+		l.MaxActionsPerHour = p.Limits.MaxActionsPerHour
+	}
+	if l.MaxActionsPerDay <= 0 {
+		l.MaxActionsPerDay = p.Limits.MaxActionsPerDay
+	}
+	if l.DelayBetweenActions == [2]int{} {
+		l.DelayBetweenActions = p.Limits.DelayBetweenActions
+	}
+	if l.CommentToDmDelay == [2]int{} {
+		l.CommentToDmDelay = p.Limits.CommentToDmDelay
+	}
+
+	cr := &out.ContentRules
+	if cr.MaxMentions <= 0 {
+		cr.MaxMentions = p.ContentRules.MaxMentions
+	}
+	if cr.MaxHashtags <= 0 {
+		cr.MaxHashtags = p.ContentRules.MaxHashtags
+	}
+	return out
+}
+
+// EffectiveCooldown mengisi scope/durasi kosong dengan default (brand, 24 jam).
+func (s SafetyConfig) EffectiveCooldown() *SafetyCooldown {
+	c := SafetyCooldown{}
+	if s.Cooldown != nil {
+		c = *s.Cooldown
+	}
+	c.Scope = strings.ToLower(strings.TrimSpace(c.Scope))
+	switch c.Scope {
+	case CooldownScopeBrand, CooldownScopeWorkflow, CooldownScopePost, CooldownScopeNone:
+	default:
+		c.Scope = CooldownScopeBrand
+	}
+	if c.DurationMinutes <= 0 {
+		c.DurationMinutes = defaultCooldownMinutes
+	}
+	return &c
+}
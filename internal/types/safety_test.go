@@ -0,0 +1,52 @@
+package types
+
+import "testing"
+
+func TestSafetyEffectiveFillsFromPreset(t *testing.T) {
+	s := SafetyConfig{
+		Enabled: true,
+		Mode:    "Conservative",
+		CombinedLimits: SafetyCombinedLimits{
+			MaxActionsPerHour: 5,
+		},
+	}
+	eff := s.Effective()
+	if eff.Mode != SafetyModeConservative {
+		t.Fatalf("expected mode %q, got %q", SafetyModeConservative, eff.Mode)
+	}
+	if eff.CombinedLimits.MaxActionsPerHour != 5 {
+		t.Fatalf("explicit hourly limit overwritten: %d", eff.CombinedLimits.MaxActionsPerHour)
+	}
+	if eff.CombinedLimits.MaxActionsPerDay != 50 {
+		t.Fatalf("expected daily limit from preset, got %d", eff.CombinedLimits.MaxActionsPerDay)
+	}
+	if eff.CombinedLimits.CommentToDmDelay != [2]int{120, 300} {
+		t.Fatalf("expected commentToDm delay from preset, got %v", eff.CombinedLimits.CommentToDmDelay)
+	}
+}
+
+func TestSafetyEffectiveDisabled(t *testing.T) {
+	s := SafetyConfig{
+		Enabled:        false,
+		ActionTypes:    SafetyActionTypes{EnableDMReply: true},
+		CombinedLimits: SafetyCombinedLimits{MaxActionsPerHour: 5, MaxActionsPerDay: 10},
+	}
+	eff := s.Effective()
+	if eff.ActionTypes.EnableCommentReply || !eff.ActionTypes.EnableDMReply {
+		t.Fatalf("disabled safety must keep the workflow action types, got %+v", eff.ActionTypes)
+	}
+	if eff.CombinedLimits.MaxActionsPerHour != 0 || eff.CombinedLimits.MaxActionsPerDay != 0 {
+		t.Fatalf("disabled safety must not limit, got %+v", eff.CombinedLimits)
+	}
+}
+
+func TestSafetyEffectiveCooldownDefaults(t *testing.T) {
+	cd := SafetyConfig{Cooldown: &SafetyCooldown{Scope: "Post"}}.EffectiveCooldown()
+	if cd.Scope != CooldownScopePost || cd.DurationMinutes != 24*60 {
+		t.Fatalf("unexpected cooldown %+v", cd)
+	}
+	cd = SafetyConfig{}.EffectiveCooldown()
+	if cd.Scope != CooldownScopeBrand {
+		t.Fatalf("expected brand scope by default, got %q", cd.Scope)
+	}
+}
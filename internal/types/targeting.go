@@ -0,0 +1,165 @@
+package types
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	PostTargetSelected = "selected"  // hanya SelectedPostID (perilaku lama)
+	PostTargetAll      = "all"       // semua post akun
+	PostTargetNextPost = "next_post" // post pertama yang terbit setelah ArmedAt
+	PostTargetCaption  = "caption"   // caption mengandung hashtag/keyword
+)
+
+// Komentar iklan vs organik.
+const (
+	AdScopeAll     = "all"
+	AdScopeOrganic = "organic_only"
+	AdScopeAds     = "ads_only"
+)
+
+// Cakupan thread komentar yang memicu trigger.
+const (
+	ReplyScopeAll            = "all"
+	ReplyScopeTopLevel       = "top_level"        // hanya komentar utama
+	ReplyScopeReplies        = "replies"          // hanya balasan di thread
+	ReplyScopeRepliesToBrand = "replies_to_brand" // hanya balasan ke komentar brand (termasuk reply bot)
+)
+
+// Media product type Graph API untuk reels.
+const MediaProductReels = "REELS"
+
+// PostTargeting: cara trigger komentar memilih post. Filter ReelsOnly dan
+// rentang tanggal berlaku di atas mode apa pun.
+type PostTargeting struct {
+	Mode string `json:"mode"` // selected | all | next_post | caption; kosong = selected
+
+	// Mode caption: "#promo" = hashtag persis, selain itu kata di caption
+	CaptionKeywords []string `json:"captionKeywords"`
+
+	ReelsOnly     bool       `json:"reelsOnly"`
+	PublishedFrom *time.Time `json:"publishedFrom,omitempty"`
+	PublishedTo   *time.Time `json:"publishedTo,omitempty"`
+
+	// Mode next_post: waktu workflow di-arm; kosong = saat event pertama diproses
+	ArmedAt *time.Time `json:"armedAt,omitempty"`
+
+	// Iklan: AdScope kosong = all. AdIDs membatasi ke iklan tertentu (komentar
+	// organik tetap lolos kecuali ads_only); ExcludeAdIDs selalu ditolak.
+	AdScope      string   `json:"adScope"`
+	AdIDs        []string `json:"adIds"`
+	ExcludeAdIDs []string `json:"excludeAdIds"`
+}
+
+// EffectiveMode mengisi mode kosong dengan default.
+func (t PostTargeting) EffectiveMode() string {
+	if t.Mode == "" {
+		return PostTargetSelected
+	}
+	return strings.ToLower(t.Mode)
+}
+
+// NeedsMedia: evaluasi butuh metadata media (caption, tipe, waktu terbit).
+func (t PostTargeting) NeedsMedia() bool {
+	switch t.EffectiveMode() {
+	case PostTargetNextPost, PostTargetCaption:
+		return true
+	}
+	return t.ReelsOnly || t.PublishedFrom != nil || t.PublishedTo != nil
+}
+
+// MatchesMedia mengecek filter yang bergantung metadata media. Mode
+// selected/all/next_post tidak memeriksa caption; pemilihan post next_post
+// dilakukan pemanggil.
+func (t PostTargeting) MatchesMedia(caption, productType string, published *time.Time) bool {
+	if t.ReelsOnly && !strings.EqualFold(productType, MediaProductReels) {
+		return false
+	}
+	if t.PublishedFrom != nil || t.PublishedTo != nil {
+		if published == nil {
+			return false
+		}
+		if t.PublishedFrom != nil && published.Before(*t.PublishedFrom) {
+			return false
+		}
+		if t.PublishedTo != nil && published.After(*t.PublishedTo) {
+			return false
+		}
+	}
+	if t.EffectiveMode() == PostTargetCaption {
+		return CaptionMatches(caption, t.CaptionKeywords)
+	}
+	return true
+}
+
+// MatchesAd mengecek filter iklan; adID kosong = komentar organik.
+func (t PostTargeting) MatchesAd(adID string) bool {
+	switch t.AdScope {
+	case AdScopeOrganic:
+		return adID == ""
+	case AdScopeAds:
+		if adID == "" {
+			return false
+		}
+	}
+	if adID == "" {
+		return true
+	}
+	for _, id := range t.ExcludeAdIDs {
+		if id == adID {
+			return false
+		}
+	}
+	if len(t.AdIDs) == 0 {
+		return true
+	}
+	for _, id := range t.AdIDs {
+		if id == adID {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAdFilter: trigger membedakan komentar iklan vs organik, jadi butuh ad id
+// yang valid (tidak tersedia untuk komentar hasil rekonsiliasi).
+func (t PostTargeting) HasAdFilter() bool {
+	return t.AdScope == AdScopeOrganic || t.AdScope == AdScopeAds ||
+		len(t.AdIDs) > 0 || len(t.ExcludeAdIDs) > 0
+}
+
+// CaptionMatches: true kalau caption memuat salah satu keyword (case-insensitive).
+// Keyword berawalan "#" harus cocok dengan hashtag utuh (#promo tidak cocok
+// dengan #promosi); keyword lain dicocokkan per kata.
+func CaptionMatches(caption string, keywords []string) bool {
+	// "#a#b" = dua hashtag
+	lower := strings.ToLower(caption)
+	words := strings.FieldsFunc(strings.ReplaceAll(lower, "#", " #"), func(r rune) bool {
+		return !(r == '#' || r == '_' || r == '\'' ||
+			r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	})
+	for _, kw := range keywords {
+		kw = strings.ToLower(strings.TrimSpace(kw))
+		if kw == "" || kw == "#" {
+			continue
+		}
+		if strings.Contains(kw, " ") {
+			// frasa: cukup substring
+			if strings.Contains(lower, kw) {
+				return true
+			}
+			continue
+		}
+		for _, w := range words {
+			if strings.HasPrefix(kw, "#") {
+				if w == kw {
+					return true
+				}
+			} else if strings.TrimLeft(w, "#") == kw {
+				return true
+			}
+		}
+	}
+	return false
+}
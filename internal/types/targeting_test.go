@@ -0,0 +1,93 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCaptionMatches(t *testing.T) {
+	caption := "Diskon akhir tahun! #Promo#sale cek link di bio"
+	cases := []struct {
+		kw   []string
+		want bool
+	}{
+		{[]string{"#promo"}, true},
+		{[]string{"#sale"}, true},
+		{[]string{"#promosi"}, false},
+		{[]string{"promo"}, true},
+		{[]string{"diskon"}, true},
+		{[]string{"disk"}, false},
+		{[]string{"link di bio"}, true},
+		{[]string{"", "#"}, false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if got := CaptionMatches(caption, c.kw); got != c.want {
+			t.Errorf("CaptionMatches(%v) = %v, want %v", c.kw, got, c.want)
+		}
+	}
+}
+
+func TestPostTargetingMatchesMedia(t *testing.T) {
+	day := func(d int) *time.Time {
+		v := time.Date(2026, 10, d, 12, 0, 0, 0, time.UTC)
+		return &v
+	}
+
+	if (PostTargeting{}).NeedsMedia() {
+		t.Fatal("default mode should not need media metadata")
+	}
+
+	reels := PostTargeting{Mode: PostTargetAll, ReelsOnly: true}
+	if !reels.NeedsMedia() {
+		t.Fatal("reelsOnly needs media metadata")
+	}
+	if reels.MatchesMedia("", "FEED", day(1)) {
+		t.Fatal("reelsOnly matched a feed post")
+	}
+	if !reels.MatchesMedia("", "REELS", day(1)) {
+		t.Fatal("reelsOnly rejected a reel")
+	}
+
+	ranged := PostTargeting{Mode: PostTargetAll, PublishedFrom: day(5), PublishedTo: day(10)}
+	for d, want := range map[int]bool{4: false, 5: true, 10: true, 11: false} {
+		if got := ranged.MatchesMedia("", "FEED", day(d)); got != want {
+			t.Errorf("date range day %d = %v, want %v", d, got, want)
+		}
+	}
+	if ranged.MatchesMedia("", "FEED", nil) {
+		t.Fatal("date range matched media without timestamp")
+	}
+
+	caption := PostTargeting{Mode: "Caption", CaptionKeywords: []string{"#giveaway"}}
+	if !caption.MatchesMedia("Ikut #giveaway sekarang", "FEED", nil) {
+		t.Fatal("caption mode rejected matching hashtag")
+	}
+	if caption.MatchesMedia("Ikut giveaway sekarang", "FEED", nil) {
+		t.Fatal("caption mode matched plain word for hashtag keyword")
+	}
+}
+
+func TestPostTargetingMatchesAd(t *testing.T) {
+	cases := []struct {
+		name string
+		t    PostTargeting
+		adID string
+		want bool
+	}{
+		{"default organic", PostTargeting{}, "", true},
+		{"default ad", PostTargeting{}, "ad1", true},
+		{"organic only rejects ad", PostTargeting{AdScope: AdScopeOrganic}, "ad1", false},
+		{"ads only rejects organic", PostTargeting{AdScope: AdScopeAds}, "", false},
+		{"ads only accepts ad", PostTargeting{AdScope: AdScopeAds}, "ad1", true},
+		{"specific ad match", PostTargeting{AdIDs: []string{"ad1"}}, "ad1", true},
+		{"specific ad other", PostTargeting{AdIDs: []string{"ad1"}}, "ad2", false},
+		{"specific ad keeps organic", PostTargeting{AdIDs: []string{"ad1"}}, "", true},
+		{"excluded ad", PostTargeting{ExcludeAdIDs: []string{"ad2"}}, "ad2", false},
+	}
+	for _, c := range cases {
+		if got := c.t.MatchesAd(c.adID); got != c.want {
+			t.Errorf("%s: MatchesAd(%q) = %v, want %v", c.name, c.adID, got, c.want)
+		}
+	}
+}
@@ -5,16 +5,26 @@ type IGWebhookEnvelope struct {
 	Object string `json:"object"`
 	Entry  []struct {
 		ID      string `json:"id"`
+		Time    int64  `json:"time"` // unix detik
 		Changes []struct {
 			Field string `json:"field"`
 			Value struct {
 				CommentID string `json:"id"`
 				PostID    string `json:"post_id"`
+				ParentID  string `json:"parent_id"` // terisi kalau balasan di thread komentar
 				Text      string `json:"text"`
 				From      struct {
 					ID       string `json:"id"`
 					Username string `json:"username"`
 				} `json:"from"`
+				// Media yang dikomentari; ad_id/ad_title terisi untuk komentar di iklan
+				Media struct {
+					ID               string `json:"id"`
+					AdID             string `json:"ad_id"`
+					AdTitle          string `json:"ad_title"`
+					OriginalMediaID  string `json:"original_media_id"`
+					MediaProductType string `json:"media_product_type"`
+				} `json:"media"`
 			} `json:"value"`
 		} `json:"changes"`
 	} `json:"entry"`
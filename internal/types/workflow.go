@@ -1,5 +1,7 @@
 package types
 
+import "encoding/json"
+
 type WorkflowTriggerType string
 type WorkflowActionType string
 
@@ -18,6 +20,8 @@ type SafetyCombinedLimits struct {
 type SafetyActionTypes struct {
 	EnableCommentReply bool `json:"enableCommentReply"`
 	EnableDMReply      bool `json:"enableDMReply"`
+	// DM tetap dikirim walau public reply gagal permanen (default: hanya setelah reply sukses)
+	DMOnReplyFailure bool `json:"dmOnReplyFailure"`
 }
 
 type SafetyContentRules struct {
@@ -25,25 +29,71 @@ type SafetyContentRules struct {
 	MaxHashtags int `json:"maxHashtags"`
 }
 
+// SafetyQuietHours: jam lokal brand tanpa aksi, format "HH:MM" (boleh lewat tengah malam).
+type SafetyQuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// SafetyCooldown: setelah DM terkirim, user yang sama tidak di-DM lagi selama
+// DurationMinutes dalam scope tertentu.
+type SafetyCooldown struct {
+	Scope               string `json:"scope"` // brand | workflow | post | none
+	DurationMinutes     int    `json:"durationMinutes"`
+	SuppressPublicReply bool   `json:"suppressPublicReply"` // cooldown juga menahan public reply
+}
+
 type SafetyConfig struct {
 	Enabled        bool                 `json:"enabled"`
 	Mode           string               `json:"mode"`
 	CombinedLimits SafetyCombinedLimits `json:"combinedLimits"`
 	ActionTypes    SafetyActionTypes    `json:"actionTypes"`
 	ContentRules   SafetyContentRules   `json:"contentRules"`
+	Timezone       string               `json:"timezone"` // IANA brand timezone, mis. "Asia/Jakarta"
+	QuietHours     *SafetyQuietHours    `json:"quietHours,omitempty"`
+	Cooldown       *SafetyCooldown      `json:"cooldown,omitempty"`
 }
 
 type IGUserCommentData struct {
-	SelectedPostID  []string `json:"selectedPostId"`
-	IncludeKeywords []string `json:"includeKeywords"`
-	ExcludeKeywords []string `json:"excludeKeywords"`
+	SelectedPostID  []string      `json:"selectedPostId"`
+	IncludeKeywords []string      `json:"includeKeywords"`
+	ExcludeKeywords []string      `json:"excludeKeywords"`
+	Targeting       PostTargeting `json:"postTargeting"`
+	ReplyScope      string        `json:"replyScope"` // all | top_level | replies | replies_to_brand; kosong = all
 }
 
 type IGReplyData struct {
-	PublicReplies []string      `json:"publicReplies"`
-	DMMessage     string        `json:"dmMessage"`
-	Buttons       []ReplyButton `json:"buttons"`
-	Safety        SafetyConfig  `json:"safetyConfig"`
+	PublicReplies []string          `json:"publicReplies"`
+	DMMessage     string            `json:"dmMessage"`
+	Buttons       []ReplyButton     `json:"buttons"`
+	QuickReplies  []ReplyQuickReply `json:"quickReplies"`
+	Media         *ReplyMedia       `json:"media,omitempty"`
+	Safety        SafetyConfig      `json:"safetyConfig"`
+}
+
+// DefaultActionTypes: workflow lama tanpa safetyConfig.actionTypes tetap
+// mengirim public reply dan DM (perilaku sebelum action types ada).
+var DefaultActionTypes = SafetyActionTypes{EnableCommentReply: true, EnableDMReply: true}
+
+// UnmarshalJSON mengisi ActionTypes dengan DefaultActionTypes kalau tidak
+// ada di JSON (bukan berarti semua aksi dimatikan).
+func (d *IGReplyData) UnmarshalJSON(b []byte) error {
+	type plain IGReplyData
+	var v plain
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	var probe struct {
+		Safety struct {
+			ActionTypes json.RawMessage `json:"actionTypes"`
+		} `json:"safetyConfig"`
+	}
+	_ = json.Unmarshal(b, &probe)
+	if at := probe.Safety.ActionTypes; len(at) == 0 || string(at) == "null" {
+		v.Safety.ActionTypes = DefaultActionTypes
+	}
+	*d = IGReplyData(v)
+	return nil
 }
 
 type ReplyButton struct {
@@ -52,6 +102,17 @@ type ReplyButton struct {
 	Enabled bool   `json:"enabled"`
 }
 
+type ReplyQuickReply struct {
+	Title   string `json:"title"`
+	Payload string `json:"payload"`
+}
+
+// ReplyMedia: lampiran DM (gambar/video) dari URL publik.
+type ReplyMedia struct {
+	Type string `json:"type"` // image | video
+	URL  string `json:"url"`
+}
+
 type Node struct {
 	ID   string                 `json:"id"`
 	Type string                 `json:"type"`
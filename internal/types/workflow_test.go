@@ -15,3 +15,25 @@ func TestNodeTypeUnmarshal(t *testing.T) {
 		t.Fatalf("expected type TRIGGER, got %q", n.Type)
 	}
 }
+
+func TestIGReplyDataDefaultActionTypes(t *testing.T) {
+	cases := []struct {
+		body string
+		want SafetyActionTypes
+	}{
+		{`{"dmMessage":"hi"}`, DefaultActionTypes},
+		{`{"safetyConfig":{"enabled":true}}`, DefaultActionTypes},
+		{`{"safetyConfig":{"actionTypes":null}}`, DefaultActionTypes},
+		{`{"safetyConfig":{"actionTypes":{"enableDMReply":true}}}`, SafetyActionTypes{EnableDMReply: true}},
+		{`{"safetyConfig":{"actionTypes":{}}}`, SafetyActionTypes{}},
+	}
+	for _, c := range cases {
+		var rd IGReplyData
+		if err := json.Unmarshal([]byte(c.body), &rd); err != nil {
+			t.Fatalf("%s: %v", c.body, err)
+		}
+		if rd.Safety.ActionTypes != c.want {
+			t.Fatalf("%s: action types %+v, want %+v", c.body, rd.Safety.ActionTypes, c.want)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+-- Status kesehatan integrasi IG (lihat repo.IntegrationStatus).
+ALTER TABLE zosmed."integration"
+    ADD COLUMN IF NOT EXISTS status            TEXT        NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS status_reason     TEXT,
+    ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS integration_account_status_idx
+    ON zosmed."integration" (account_id, status);
@@ -0,0 +1,15 @@
+-- Katalog media IG per akun (disinkron dari Graph API, lihat service.MediaCatalog).
+CREATE TABLE IF NOT EXISTS zosmed."ig_media" (
+    id                 TEXT PRIMARY KEY,          -- IG media id
+    account_id         TEXT        NOT NULL,      -- IG business account id
+    permalink          TEXT,
+    shortcode          TEXT,
+    caption            TEXT,
+    media_type         TEXT,                      -- IMAGE | VIDEO | CAROUSEL_ALBUM
+    media_product_type TEXT,                      -- FEED | REELS | STORY | AD
+    "timestamp"        TIMESTAMPTZ,
+    synced_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS ig_media_account_shortcode_idx ON zosmed."ig_media" (account_id, shortcode);
+CREATE INDEX IF NOT EXISTS ig_media_account_timestamp_idx ON zosmed."ig_media" (account_id, "timestamp" DESC);
@@ -0,0 +1,31 @@
+-- Satu akun IG = satu baris integrasi (lihat repo.IntegrationRepo.UpsertInstagram).
+-- Duplikat lama: baris terbaru dipertahankan; sisanya di-revoke, workflow-nya
+-- dinonaktifkan, dan account_id diberi suffix supaya index bisa dibuat.
+WITH ranked AS (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY account_id, type ORDER BY updated_at DESC, id) AS rn
+    FROM zosmed."integration"
+    WHERE account_id IS NOT NULL AND account_id <> ''
+)
+UPDATE zosmed."workflow" w
+SET is_active = FALSE
+FROM ranked r
+WHERE r.id = w.integration_id AND r.rn > 1;
+
+WITH ranked AS (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY account_id, type ORDER BY updated_at DESC, id) AS rn
+    FROM zosmed."integration"
+    WHERE account_id IS NOT NULL AND account_id <> ''
+)
+UPDATE zosmed."integration" i
+SET account_id        = i.account_id || ':dup:' || i.id::text,
+    access_token      = '',
+    status            = 'revoked',
+    status_reason     = 'duplicate account (0003)',
+    status_changed_at = NOW(),
+    updated_at        = NOW()
+FROM ranked r
+WHERE r.id = i.id AND r.rn > 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS integration_account_type_uniq
+    ON zosmed."integration" (account_id, type)
+    WHERE account_id IS NOT NULL AND account_id <> '';
@@ -0,0 +1,4 @@
+-- Timezone akun IG/brand: limit harian brand & akun dihitung per hari lokal
+-- akun ini (bukan timezone workflow). NULL = DEFAULT_TIMEZONE.
+ALTER TABLE zosmed."integration"
+    ADD COLUMN IF NOT EXISTS timezone TEXT;